@@ -0,0 +1,82 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// canEditHeader tells clients whether they may write to the calendar, so they
+// can hide edit and delete actions that would fail with 403
+const canEditHeader = "X-Calendar-Can-Edit"
+
+// accessKey identifies a cached accessRole: the calendar, and the subject
+// header of the request, empty when not delegating
+type accessKey struct {
+	calendar, subject string
+}
+
+// accessRole is an accessRole and when it was fetched
+type accessRole struct {
+	role    string
+	fetched time.Time
+}
+
+// accessCache holds the user's accessRole on each calendar, fetched from
+// Google and refetched once older than AccessRoleCacheTTL. It's safe for
+// concurrent use
+type accessCache struct {
+	mu    sync.RWMutex
+	roles map[accessKey]accessRole
+}
+
+// calendarAccess is the accessRole cache shared by all requests
+var calendarAccess accessCache
+
+// role returns the accessRole for key, fetching it when it isn't cached or
+// has gone stale
+func (c *accessCache) role(ctx context.Context, key accessKey) (string, error) {
+	c.mu.RLock()
+	cached, ok := c.roles[key]
+	c.mu.RUnlock()
+	if ok && time.Since(cached.fetched) < AccessRoleCacheTTL {
+		return cached.role, nil
+	}
+
+	entry, err := srv.CalendarList.Get(key.calendar).Fields("accessRole").Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	if c.roles == nil {
+		c.roles = map[accessKey]accessRole{}
+	}
+	// Dropping stale entries keeps the map from growing with every subject
+	// header ever sent
+	for k, v := range c.roles {
+		if time.Since(v.fetched) >= AccessRoleCacheTTL {
+			delete(c.roles, k)
+		}
+	}
+	c.roles[key] = accessRole{role: entry.AccessRole, fetched: time.Now()}
+	c.mu.Unlock()
+	return entry.AccessRole, nil
+}
+
+// setCanEdit sets canEditHeader from the user's accessRole on the calendar.
+// The header is left out if the role can't be fetched
+func setCanEdit(w http.ResponseWriter, r *http.Request) {
+	key := accessKey{CalendarID, strings.ToLower(r.Header.Get(subjectHeader))}
+	role, err := calendarAccess.role(r.Context(), key)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	canEdit := role == "owner" || role == "writer"
+	w.Header().Set(canEditHeader, strconv.FormatBool(canEdit))
+}
@@ -0,0 +1,55 @@
+package calendar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestSetCanEditCached(t *testing.T) {
+	calendarAccess = accessCache{}
+	defer func() { calendarAccess = accessCache{} }()
+	calls := map[string]int{}
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		switch r.URL.Path {
+		case "/users/me/calendarList/primary":
+			writeJSON(w, &calendar.CalendarListEntry{AccessRole: "writer"})
+		case "/users/me/calendarList/team":
+			writeJSON(w, &calendar.CalendarListEntry{AccessRole: "reader"})
+		default:
+			googleError(w, http.StatusNotFound, "notFound")
+		}
+	})
+	defer func(id string) { CalendarID = id }(CalendarID)
+
+	tests := []struct {
+		calendar, subject, want string
+	}{
+		{"primary", "", "true"},
+		{"primary", "", "true"},
+		{"team", "", "false"},
+		{"team", "", "false"},
+		{"primary", "ann@example.com", "true"},
+	}
+	for _, tt := range tests {
+		CalendarID = tt.calendar
+		req := httptest.NewRequest("GET", "/events/upcoming", nil)
+		if tt.subject != "" {
+			req.Header.Set(subjectHeader, tt.subject)
+		}
+		w := httptest.NewRecorder()
+		setCanEdit(w, req)
+		if got := w.Header().Get(canEditHeader); got != tt.want {
+			t.Errorf("%s %q: %s = %q, want %q", tt.calendar, tt.subject, canEditHeader, got, tt.want)
+		}
+	}
+	if n := calls["/users/me/calendarList/primary"]; n != 2 {
+		t.Errorf("primary fetched %d times, want once per subject", n)
+	}
+	if n := calls["/users/me/calendarList/team"]; n != 1 {
+		t.Errorf("team fetched %d times, want 1", n)
+	}
+}
@@ -0,0 +1,176 @@
+package calendar
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/calendar/v3"
+)
+
+const maxAgendaCalendars = 20 // most calendars AgendaEvents merges
+
+// AgendaEvents method merges the events between start and end of each calendar
+// in the calendars query param (comma separated ids), ordered by start time
+// and tagged with their calendar id. Calendars are fetched concurrently, at
+// most CalendarConcurrency at once
+func AgendaEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "AgendaEvents")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	ids, err := calendarIDs(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	start, end, err := timeRange(r, true)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	apply, err := listParams(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	filter, err := eventFilter(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	conv, err := eventConverter(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := eventColors.refresh(r.Context()); err != nil {
+		log.Println(err.Error())
+	}
+
+	var mu sync.Mutex
+	res := []*jEvent{}
+	err = fetchCalendars(r.Context(), ids, func(ctx context.Context, calID string) error {
+		call := srv.Events.List(calID).
+			ShowDeleted(false).
+			SingleEvents(true).
+			TimeMin(start.Format(time.RFC3339)).
+			TimeMax(end.Format(time.RFC3339)).
+			OrderBy("startTime")
+		apply(call)
+		return call.Fields(listFields()).Pages(ctx, func(events *calendar.Events) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, i := range events.Items {
+				if filter(i) {
+					ev := conv(i)
+					ev.CalendarID = calID
+					res = append(res, ev)
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve calendar events: "+err.Error())
+		return
+	}
+
+	sort.Slice(res, func(a, b int) bool { return lessEvent(res[a], res[b]) })
+	respond(w, r, http.StatusOK, res)
+}
+
+// lessEvent orders merged events by start time, then calendar id, then event
+// id, so the output is deterministic when events start at the same time
+func lessEvent(a, b *jEvent) bool {
+	if !a.start.Equal(b.start) {
+		return a.start.Before(b.start)
+	}
+	if a.CalendarID != b.CalendarID {
+		return a.CalendarID < b.CalendarID
+	}
+	return a.ID < b.ID
+}
+
+// calendarIDs parses the comma separated calendars query param, each of which
+// must be allowed by calendarAllowed
+func calendarIDs(r *http.Request) ([]string, error) {
+	v := r.URL.Query().Get("calendars")
+	if v == "" {
+		return nil, invalidRequest("missing calendars")
+	}
+	ids := strings.Split(v, ",")
+	if len(ids) > maxAgendaCalendars {
+		return nil, invalidRequest(fmt.Sprintf("at most %d calendars", maxAgendaCalendars))
+	}
+	for _, id := range ids {
+		if strings.TrimSpace(id) == "" {
+			return nil, invalidRequest("empty calendar id")
+		}
+		if !calendarAllowed(id) {
+			return nil, &apiError{Status: http.StatusForbidden, Msg: "calendar not allowed: " + id}
+		}
+	}
+	return ids, nil
+}
+
+// calendarAllowed reports whether clients may select the calendar with id
+func calendarAllowed(id string) bool {
+	if id == CalendarID {
+		return true
+	}
+	if CalendarAllowed != nil {
+		return CalendarAllowed(id)
+	}
+	return contains(AllowedCalendars, id)
+}
+
+// fetchCalendars calls fn for each calendar id with at most CalendarConcurrency
+// running at once. The first error cancels the calls still running, as does
+// ctx being done, e.g. when the client disconnects
+func fetchCalendars(ctx context.Context, ids []string, fn func(ctx context.Context, calID string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n := CalendarConcurrency
+	if n < 1 {
+		n = 1
+	}
+	sem := make(chan struct{}, n)
+	errc := make(chan error, len(ids))
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, id); err != nil {
+				errc <- fmt.Errorf("%s: %w", id, err)
+				cancel()
+			}
+		}(id)
+	}
+	wg.Wait()
+	close(errc)
+	if err := <-errc; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,134 @@
+package calendar
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestFetchCalendarsConcurrency(t *testing.T) {
+	defer func(n int) { CalendarConcurrency = n }(CalendarConcurrency)
+	CalendarConcurrency = 3
+
+	ids := make([]string, 12)
+	var mu sync.Mutex
+	running, most, calls := 0, 0, 0
+	err := fetchCalendars(context.Background(), ids, func(ctx context.Context, calID string) error {
+		mu.Lock()
+		running++
+		calls++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(ids) {
+		t.Errorf("%d calls, want %d", calls, len(ids))
+	}
+	if most > CalendarConcurrency {
+		t.Errorf("%d calls at once, want at most %d", most, CalendarConcurrency)
+	}
+}
+
+func TestFetchCalendarsError(t *testing.T) {
+	defer func(n int) { CalendarConcurrency = n }(CalendarConcurrency)
+	CalendarConcurrency = 1
+
+	fail := errors.New("boom")
+	calls := 0
+	err := fetchCalendars(context.Background(), []string{"a", "b", "c"}, func(ctx context.Context, calID string) error {
+		calls++
+		if calID == "a" {
+			return fail
+		}
+		return nil
+	})
+	if !errors.Is(err, fail) {
+		t.Errorf("err = %v, want %v", err, fail)
+	}
+	if calls != 1 {
+		t.Errorf("%d calls, want the error to stop the rest", calls)
+	}
+}
+
+func TestLessEventSameStart(t *testing.T) {
+	at := time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC)
+	evs := []*jEvent{
+		{ID: "b", CalendarID: "work", start: at},
+		{ID: "z", CalendarID: "home", start: at.Add(time.Hour)},
+		{ID: "a", CalendarID: "work", start: at},
+		{ID: "c", CalendarID: "home", start: at},
+	}
+	want := []string{"home/c", "work/a", "work/b", "home/z"}
+	for run := 0; run < 5; run++ {
+		res := append([]*jEvent{}, evs...)
+		// Start from a different order each run, the result must not change
+		res[0], res[run%len(res)] = res[run%len(res)], res[0]
+		sort.Slice(res, func(a, b int) bool { return lessEvent(res[a], res[b]) })
+		for idx, ev := range res {
+			if got := ev.CalendarID + "/" + ev.ID; got != want[idx] {
+				t.Fatalf("run %d: position %d is %s, want %s", run, idx, got, want[idx])
+			}
+		}
+	}
+}
+
+func TestCalendarAllowed(t *testing.T) {
+	defer func(ids []string, fn func(string) bool) {
+		AllowedCalendars, CalendarAllowed = ids, fn
+	}(AllowedCalendars, CalendarAllowed)
+
+	AllowedCalendars, CalendarAllowed = []string{"team@group.calendar.google.com"}, nil
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{CalendarID, true},
+		{"team@group.calendar.google.com", true},
+		{"someone@example.com", false},
+		{"TEAM@group.calendar.google.com", false},
+	}
+	for _, tt := range tests {
+		if got := calendarAllowed(tt.id); got != tt.want {
+			t.Errorf("calendarAllowed(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+
+	// A CalendarAllowed func takes over from the list
+	CalendarAllowed = func(id string) bool { return strings.HasSuffix(id, "@example.com") }
+	if !calendarAllowed("someone@example.com") || calendarAllowed("team@group.calendar.google.com") {
+		t.Error("CalendarAllowed not used")
+	}
+}
+
+func TestCalendarIDsDenied(t *testing.T) {
+	defer func(ids []string, fn func(string) bool) {
+		AllowedCalendars, CalendarAllowed = ids, fn
+	}(AllowedCalendars, CalendarAllowed)
+	AllowedCalendars, CalendarAllowed = []string{"team@group.calendar.google.com"}, nil
+
+	r := httptest.NewRequest("GET", "/events/agenda?calendars=primary,other@example.com", nil)
+	_, err := calendarIDs(r)
+	if aerr, ok := err.(*apiError); !ok || aerr.Status != http.StatusForbidden {
+		t.Errorf("err = %v, want a 403 apiError", err)
+	}
+	r = httptest.NewRequest("GET", "/events/agenda?calendars=primary,team@group.calendar.google.com", nil)
+	if ids, err := calendarIDs(r); err != nil || len(ids) != 2 {
+		t.Errorf("got %v, %v", ids, err)
+	}
+}
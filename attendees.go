@@ -0,0 +1,221 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/calendar/v3"
+)
+
+// responseSummary counts attendee responses for a quick UI indicator
+type responseSummary struct {
+	Accepted    int `json:"accepted"`
+	Declined    int `json:"declined"`
+	Tentative   int `json:"tentative"`
+	NeedsAction int `json:"needsAction"`
+	// Guests are the additional guests the accepted attendees bring along
+	Guests int `json:"guests"`
+}
+
+// Headcount is the number of people expected: accepted attendees and their guests
+func (s *responseSummary) Headcount() int {
+	return s.Accepted + s.Guests
+}
+
+// summarizeResponses tallies the responseStatus of each attendee
+func summarizeResponses(attendees []*calendar.EventAttendee) *responseSummary {
+	sum := &responseSummary{}
+	for _, a := range attendees {
+		switch a.ResponseStatus {
+		case "accepted":
+			sum.Accepted++
+			sum.Guests += int(a.AdditionalGuests)
+		case "declined":
+			sum.Declined++
+		case "tentative":
+			sum.Tentative++
+		case "needsAction":
+			sum.NeedsAction++
+		}
+	}
+	return sum
+}
+
+// hasAttendee reports whether email is among the attendees
+func hasAttendee(attendees []*calendar.EventAttendee, email string) bool {
+	for _, a := range attendees {
+		if strings.EqualFold(a.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// selfDeclined reports whether the user's own attendee entry has declined
+func selfDeclined(attendees []*calendar.EventAttendee) bool {
+	for _, a := range attendees {
+		if a.Self {
+			return a.ResponseStatus == "declined"
+		}
+	}
+	return false
+}
+
+// resourceDomain is the domain of Google Workspace resource (room) calendars
+const resourceDomain = "@resource.calendar.google.com"
+
+// newAttendee is an attendee submitted with a newEvent. Rooms and other
+// resources are invited by their resource calendar address with Resource set
+type newAttendee struct {
+	Email    string
+	Optional bool
+	Resource bool
+}
+
+// assembleAttendees validates the submitted attendees and converts them for
+// Google, normalizing the addresses and dropping duplicates, which keep the
+// first entry. All the invalid addresses are reported together
+func assembleAttendees(list []newAttendee) ([]*calendar.EventAttendee, error) {
+	res := []*calendar.EventAttendee{}
+	seen := map[string]bool{}
+	var invalid []string
+	for _, a := range list {
+		email, err := normalizeEmail(a.Email)
+		if err != nil {
+			invalid = append(invalid, a.Email)
+			continue
+		}
+		if a.Resource && !strings.HasSuffix(email, resourceDomain) {
+			return nil, invalidRequest("not a resource calendar address: " + a.Email)
+		}
+		if seen[strings.ToLower(email)] {
+			continue
+		}
+		seen[strings.ToLower(email)] = true
+		res = append(res, &calendar.EventAttendee{
+			Email:    email,
+			Optional: a.Optional,
+			Resource: a.Resource,
+		})
+	}
+	if invalid != nil {
+		return nil, invalidRequest("invalid attendee emails: " + strings.Join(invalid, ", "))
+	}
+	return res, nil
+}
+
+// normalizeEmail parses a submitted address, which may include a display
+// name, down to the bare address with its domain lowercased
+func normalizeEmail(v string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(v))
+	if err != nil {
+		return "", err
+	}
+	at := strings.LastIndex(addr.Address, "@")
+	return addr.Address[:at] + strings.ToLower(addr.Address[at:]), nil
+}
+
+// roomConflicts returns the resources that declined, which Google does when a room is already booked
+func roomConflicts(attendees []*calendar.EventAttendee) []string {
+	var res []string
+	for _, a := range attendees {
+		if a.Resource && a.ResponseStatus == "declined" {
+			res = append(res, a.Email)
+		}
+	}
+	return res
+}
+
+type resourceCalendar struct {
+	ID      string `json:"id"`
+	Summary string `json:"summary"`
+}
+
+// ResourceCalendars method lists the resource (room) calendars in the user's
+// calendar list. Listing every resource in the domain needs the Directory API
+// and an admin scope, so rooms must have been added to the user's calendars
+func ResourceCalendars(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ResourceCalendars")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	res := []*resourceCalendar{}
+	err := srv.CalendarList.List().
+		Fields("nextPageToken,items(id,summary)").
+		Pages(r.Context(), func(list *calendar.CalendarList) error {
+			for _, c := range list.Items {
+				if strings.HasSuffix(strings.ToLower(c.Id), resourceDomain) {
+					res = append(res, &resourceCalendar{ID: c.Id, Summary: c.Summary})
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's calendars")
+		return
+	}
+	respond(w, r, http.StatusOK, res)
+}
+
+// RemoveAttendee method removes the attendee with email from the event with
+// id, leaving the other attendees as they are, and responds with the
+// remaining attendees
+func RemoveAttendee(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "RemoveAttendee")
+	defer span.End()
+
+	// Restrict method to delete only
+	if r.Method != "DELETE" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	vars := mux.Vars(r)
+	if vars["id"] == "" {
+		respondErr(w, r, http.StatusForbidden, "invalid request, missing event id")
+		return
+	}
+	email, err := normalizeEmail(vars["email"])
+	if err != nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, invalid attendee email: "+vars["email"])
+		return
+	}
+
+	ev, err := srv.Events.Get(CalendarID, vars["id"]).Fields("attendees").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if !hasAttendee(ev.Attendees, email) {
+		respondErr(w, r, http.StatusNotFound, "attendee not found: "+email)
+		return
+	}
+	attendees := []*calendar.EventAttendee{}
+	for _, a := range ev.Attendees {
+		if !strings.EqualFold(a.Email, email) {
+			attendees = append(attendees, a)
+		}
+	}
+
+	// Sent even when empty, so removing the last attendee clears the list
+	patch := &calendar.Event{Attendees: attendees, ForceSendFields: []string{"Attendees"}}
+	ev, err = srv.Events.Patch(CalendarID, vars["id"], patch).Fields("attendees").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	if ev.Attendees == nil {
+		ev.Attendees = []*calendar.EventAttendee{}
+	}
+	respond(w, r, http.StatusOK, ev.Attendees)
+}
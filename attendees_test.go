@@ -0,0 +1,58 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssembleAttendeesDuplicates(t *testing.T) {
+	got, err := assembleAttendees([]newAttendee{
+		{Email: "Ann <ann@Example.com>"},
+		{Email: "bob@example.com", Optional: true},
+		{Email: "ann@example.com", Optional: true},
+		{Email: " bob@EXAMPLE.COM "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d attendees, want 2", len(got))
+	}
+	// Duplicates keep the first entry
+	if got[0].Email != "ann@example.com" || got[0].Optional {
+		t.Errorf("first = %+v, want required ann@example.com", got[0])
+	}
+	if got[1].Email != "bob@example.com" || !got[1].Optional {
+		t.Errorf("second = %+v, want optional bob@example.com", got[1])
+	}
+}
+
+func TestAssembleAttendeesMalformed(t *testing.T) {
+	_, err := assembleAttendees([]newAttendee{
+		{Email: "ann@example.com"},
+		{Email: "not an address"},
+		{Email: "bob@"},
+	})
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	// All the invalid addresses are reported together
+	for _, v := range []string{"not an address", "bob@"} {
+		if !strings.Contains(err.Error(), v) {
+			t.Errorf("error %q doesn't name %q", err, v)
+		}
+	}
+	if strings.Contains(err.Error(), "ann@example.com") {
+		t.Errorf("error %q names a valid address", err)
+	}
+}
+
+func TestAssembleAttendeesResource(t *testing.T) {
+	if _, err := assembleAttendees([]newAttendee{{Email: "room@example.com", Resource: true}}); err == nil {
+		t.Error("want an error for a resource outside " + resourceDomain)
+	}
+	got, err := assembleAttendees([]newAttendee{{Email: "c_123" + resourceDomain, Resource: true}})
+	if err != nil || len(got) != 1 || !got[0].Resource {
+		t.Errorf("got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,64 @@
+package calendar
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// RequireAuth wraps next so requests must carry one of APIKeys, either as
+// "Authorization: Bearer <key>" or an X-API-Key header, and are refused
+// with 401 otherwise. APIKeys is read on each request, requests going through
+// while it's empty. RegisterRoutes applies it to every route
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(APIKeys) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := r.Header.Get("X-API-Key")
+		if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+			key = strings.TrimPrefix(h, "Bearer ")
+		}
+		if key == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="calendar"`)
+			respondErr(w, r, http.StatusUnauthorized, "missing credentials")
+			return
+		}
+		if !validKey(key) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="calendar", error="invalid_token"`)
+			respondErr(w, r, http.StatusUnauthorized, "invalid credentials")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireService wraps next so requests are refused with 503 while the
+// calendar service couldn't be created, rather than failing on a nil service.
+// RegisterRoutes applies it to every route, and handlers wired by hand need
+// wrapping with it too
+func RequireService(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if srv == nil {
+			if srvErr != nil {
+				log.Println(srvErr.Error())
+			}
+			respondErr(w, r, http.StatusServiceUnavailable, "calendar service unavailable")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validKey compares key against each of APIKeys in constant time
+func validKey(key string) bool {
+	ok := false
+	for _, k := range APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			ok = true
+		}
+	}
+	return ok
+}
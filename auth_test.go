@@ -0,0 +1,52 @@
+package calendar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestRequireAuthKeysSetLate(t *testing.T) {
+	fakeEventPages(t, &calendar.Events{})
+	defer func(k []string) { APIKeys = k }(APIKeys)
+	APIKeys = nil
+	r := mux.NewRouter()
+	RegisterRoutes(r, "/api")
+	APIKeys = []string{"secret"}
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong", "Bearer nope", http.StatusUnauthorized},
+		{"valid", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/events/upcoming", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireServiceUnavailable(t *testing.T) {
+	defer func(s *calendar.Service) { srv = s }(srv)
+	srv = nil
+	w := httptest.NewRecorder()
+	RequireService(http.HandlerFunc(UpcomingEvents)).ServeHTTP(w, httptest.NewRequest("GET", "/events/upcoming", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
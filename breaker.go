@@ -0,0 +1,130 @@
+package calendar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states, as returned by BreakerState
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// breaker counts consecutive failed Google calls. Once BreakerThreshold fail
+// it opens for BreakerCooldown, then half-opens to let one trial call through,
+// which closes it on success and reopens it on failure
+type breaker struct {
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+}
+
+// googleBreaker guards all the calls to Google
+var googleBreaker breaker
+
+// BreakerState returns the state of the circuit breaker on Google calls,
+// closed, open or half-open, for health checks
+func BreakerState() string {
+	return googleBreaker.state(time.Now())
+}
+
+func (b *breaker) state(now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stateLocked(now)
+}
+
+func (b *breaker) stateLocked(now time.Time) string {
+	if BreakerThreshold <= 0 || b.failures < BreakerThreshold {
+		return breakerClosed
+	}
+	if now.Sub(b.openedAt) < BreakerCooldown {
+		return breakerOpen
+	}
+	return breakerHalfOpen
+}
+
+// allow reports whether a call may go ahead
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateLocked(now) {
+	case breakerOpen:
+		return false
+	case breakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+// record notes the outcome of a call allowed through
+func (b *breaker) record(failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= BreakerThreshold {
+		b.openedAt = now
+	}
+}
+
+// release ends a call allowed through without recording its outcome
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// breakerTransport short-circuits Google calls while googleBreaker is open,
+// answering them with a 503 so handlers pass that on as for Google's own
+type breakerTransport struct {
+	base http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !googleBreaker.allow(time.Now()) {
+		return breakerResponse(req), nil
+	}
+	res, err := t.base.RoundTrip(req)
+	// Only Google failing counts, not requests it rejects or callers giving up
+	if req.Context().Err() != nil {
+		googleBreaker.release()
+		return res, err
+	}
+	failed := err != nil || res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+	googleBreaker.record(failed, time.Now())
+	return res, err
+}
+
+// breakerResponse is the 503 given to calls short-circuited by the breaker
+func breakerResponse(req *http.Request) *http.Response {
+	body := `{"error":{"code":503,"message":"Google Calendar is failing, calls are paused"}}`
+	return &http.Response{
+		Status:     "503 Service Unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type": {"application/json"},
+			"Retry-After":  {strconv.Itoa(int(BreakerCooldown.Seconds()))},
+		},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,127 @@
+package calendar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBreakerStates(t *testing.T) {
+	defer func(n int, d time.Duration) { BreakerThreshold, BreakerCooldown = n, d }(BreakerThreshold, BreakerCooldown)
+	BreakerThreshold, BreakerCooldown = 3, time.Minute
+
+	var b breaker
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	step := func(want string) {
+		t.Helper()
+		if got := b.state(now); got != want {
+			t.Fatalf("state = %s, want %s", got, want)
+		}
+	}
+
+	// Failures below the threshold, or broken by a success, leave it closed
+	for _, failed := range []bool{true, true, false, true, true} {
+		if !b.allow(now) {
+			t.Fatal("closed breaker refused a call")
+		}
+		b.record(failed, now)
+	}
+	step(breakerClosed)
+
+	b.allow(now)
+	b.record(true, now)
+	step(breakerOpen)
+	if b.allow(now.Add(BreakerCooldown - time.Second)) {
+		t.Error("open breaker allowed a call")
+	}
+
+	// Half-open lets one trial through, a failure reopening it
+	now = now.Add(BreakerCooldown)
+	step(breakerHalfOpen)
+	if !b.allow(now) {
+		t.Fatal("half-open breaker refused the trial")
+	}
+	if b.allow(now) {
+		t.Error("half-open breaker allowed a second call during the trial")
+	}
+	b.record(true, now)
+	step(breakerOpen)
+
+	// And a successful trial closing it
+	now = now.Add(BreakerCooldown)
+	step(breakerHalfOpen)
+	b.allow(now)
+	b.record(false, now)
+	step(breakerClosed)
+	if !b.allow(now) || !b.allow(now) {
+		t.Error("closed breaker refused calls")
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	defer func(n int) { BreakerThreshold = n }(BreakerThreshold)
+	BreakerThreshold = 0
+
+	var b breaker
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		b.allow(now)
+		b.record(true, now)
+	}
+	if got := b.state(now); got != breakerClosed {
+		t.Errorf("state = %s, want %s", got, breakerClosed)
+	}
+}
+
+// failingTransport answers every call with status
+type failingTransport struct {
+	status int
+	calls  int
+}
+
+func (t *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls++
+	return &http.Response{StatusCode: t.status, Body: ioutil.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+func TestBreakerTransport(t *testing.T) {
+	defer func(n int, d time.Duration) { BreakerThreshold, BreakerCooldown = n, d }(BreakerThreshold, BreakerCooldown)
+	BreakerThreshold, BreakerCooldown = 2, time.Minute
+	googleBreaker = breaker{}
+	defer func() { googleBreaker = breaker{} }()
+
+	base := &failingTransport{status: http.StatusBadGateway}
+	client := &http.Client{Transport: &breakerTransport{base: base}}
+	for i := 0; i < 4; i++ {
+		res, err := client.Get("http://calendar.test/events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if i >= 2 && (res.StatusCode != http.StatusServiceUnavailable || res.Header.Get("Retry-After") != "60") {
+			t.Errorf("call %d: status %d, Retry-After %q", i, res.StatusCode, res.Header.Get("Retry-After"))
+		}
+	}
+	if base.calls != 2 {
+		t.Errorf("%d calls reached Google, want 2 before opening", base.calls)
+	}
+	if got := BreakerState(); got != breakerOpen {
+		t.Errorf("state = %s, want %s", got, breakerOpen)
+	}
+
+	// Requests Google rejects don't count as it failing
+	googleBreaker = breaker{}
+	base.status = http.StatusNotFound
+	for i := 0; i < 4; i++ {
+		res, err := client.Get("http://calendar.test/events")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+	if got := BreakerState(); got != breakerClosed {
+		t.Errorf("state after 404s = %s, want %s", got, breakerClosed)
+	}
+}
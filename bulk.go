@@ -0,0 +1,347 @@
+package calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	maxBulkIDs      = 100 // most event ids accepted by a bulk request
+	bulkConcurrency = 4   // Google calls in flight at once for a bulk request
+	maxAttempts     = 3   // tries for a call failing with transient errors
+)
+
+type bulkPatch struct {
+	IDs   []string `json:"ids"`
+	Patch newEvent `json:"patch"`
+}
+
+// batchResult is the outcome of one item of a batch request, with the HTTP
+// status the item would have had as a request of its own
+type batchResult struct {
+	Index   int    `json:"index"`
+	Status  int    `json:"status"`
+	ID      string `json:"id,omitempty"`
+	ICalUID string `json:"iCalUID,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// batchResponse is the multi-status body of the batch handlers, e.g.
+//
+//	{"results": [{"index": 0, "status": 200, "id": "abc"},
+//		{"index": 1, "status": 404, "id": "def", "error": "Not Found"}]}
+//
+// The batch request itself succeeds with 200 even when some items fail, so
+// clients must check each result's status
+type batchResponse struct {
+	Results []*batchResult `json:"results"`
+}
+
+// fail records err on the result, with status taken from a Google API error,
+// or 504 when the request ran out of time
+func (b *batchResult) fail(err error) {
+	b.Status = http.StatusInternalServerError
+	var aerr *apiError
+	if errors.As(err, &aerr) {
+		b.Status = aerr.Status
+	}
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		b.Status = gerr.Code
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		b.Status = http.StatusGatewayTimeout
+	}
+	b.Error = err.Error()
+}
+
+// BulkPatchEvents method applies one partial newEvent patch to each of a list of event ids
+// e.g. {"ids": ["abc", "def"], "patch": {"color": "Sage"}}. Responds with a batchResponse
+func BulkPatchEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "BulkPatchEvents")
+	defer span.End()
+
+	// Restrict method to post only
+	if r.Method != "POST" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	var req bulkPatch
+	if err := decodeBody(r, &req); err != nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxBulkIDs {
+		respondErr(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request, between 1 and %d ids required", maxBulkIDs))
+		return
+	}
+	evt, err := assembleEvent(&req.Patch)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if b, _ := json.Marshal(evt); string(b) == "{}" {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, empty patch")
+		return
+	}
+
+	res := make([]*batchResult, len(req.IDs))
+	skipped := forEachID(r.Context(), req.IDs, func(idx int, id string) {
+		res[idx] = &batchResult{Index: idx, Status: http.StatusOK, ID: id}
+		err := retry(r.Context(), func() error {
+			_, err := srv.Events.Patch(CalendarID, id, evt).Fields("id").Context(r.Context()).Do()
+			return err
+		})
+		if err != nil {
+			log.Println(err.Error())
+			res[idx].fail(err)
+		}
+	})
+	for _, idx := range skipped {
+		res[idx] = &batchResult{Index: idx, ID: req.IDs[idx]}
+		res[idx].fail(r.Context().Err())
+	}
+	respond(w, r, http.StatusOK, &batchResponse{Results: res})
+}
+
+type bulkFetch struct {
+	IDs []string `json:"ids"`
+}
+
+// fetchedEvent is one event of a BulkFetchEvents response, Status being 404
+// for an id that doesn't exist and 410 for a deleted event
+type fetchedEvent struct {
+	Status int     `json:"status"`
+	Event  *jEvent `json:"event,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// BulkFetchEvents method fetches each of a list of event ids, e.g. {"ids":
+// ["abc", "def"]}, responding with a map of id to fetchedEvent. The Go client
+// has no batch endpoint support, so they're fetched concurrently instead
+func BulkFetchEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "BulkFetchEvents")
+	defer span.End()
+
+	// Restrict method to post only
+	if r.Method != "POST" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	var req bulkFetch
+	if err := decodeBody(r, &req); err != nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxBulkIDs {
+		respondErr(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request, between 1 and %d ids required", maxBulkIDs))
+		return
+	}
+	conv, err := eventConverter(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := eventColors.refresh(r.Context()); err != nil {
+		log.Println(err.Error())
+	}
+
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	res := make([]*fetchedEvent, len(ids))
+	skipped := forEachID(r.Context(), ids, func(idx int, id string) {
+		var ev *calendar.Event
+		err := retry(r.Context(), func() error {
+			var err error
+			ev, err = srv.Events.Get(CalendarID, id).Fields(jEventFields()).Context(r.Context()).Do()
+			return err
+		})
+		switch {
+		case err != nil:
+			b := &batchResult{}
+			b.fail(err)
+			res[idx] = &fetchedEvent{Status: b.Status, Error: b.Error}
+		case ev.Status == "cancelled":
+			res[idx] = &fetchedEvent{Status: http.StatusGone}
+		default:
+			res[idx] = &fetchedEvent{Status: http.StatusOK, Event: conv(ev)}
+		}
+	})
+	for _, idx := range skipped {
+		b := &batchResult{}
+		b.fail(r.Context().Err())
+		res[idx] = &fetchedEvent{Status: b.Status, Error: b.Error}
+	}
+	out := map[string]*fetchedEvent{}
+	for idx, id := range ids {
+		out[id] = res[idx]
+	}
+	respond(w, r, http.StatusOK, out)
+}
+
+// deleteReport is a batchResponse with counts of the deleted and failed events
+type deleteReport struct {
+	Deleted int            `json:"deleted"`
+	Failed  int            `json:"failed"`
+	Results []*batchResult `json:"results"`
+}
+
+// DeleteTaggedEvents method deletes every event tagged with the private extended
+// property given as ?property=key=value, e.g. all the events an app imported,
+// optionally limited to those between start and end. Recurring events are
+// deleted with their whole series. As this can't be undone it takes two steps:
+// a GET previews it, responding with a confirmPreview, and a DELETE with the
+// same params and the preview's token as confirm does it
+func DeleteTaggedEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "DeleteTaggedEvents")
+	defer span.End()
+
+	// Restrict method to get and delete only
+	if r.Method != "GET" && r.Method != "DELETE" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	q := r.URL.Query()
+	prop := q.Get("property")
+	if err := validPropertyFilter(prop); err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	start, end, err := timeRange(r, false)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	op := "tagged " + prop + " " + q.Get("start") + " " + q.Get("end")
+	if r.Method == "DELETE" {
+		if q.Get("confirm") == "" {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, confirm token from a GET preview is required to delete events")
+			return
+		}
+		if err := useConfirmToken(q.Get("confirm"), op); err != nil {
+			respondError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	call := srv.Events.List(CalendarID).
+		ShowDeleted(false).
+		PrivateExtendedProperty(prop).
+		Fields("nextPageToken,items(id)")
+	if !start.IsZero() {
+		call.TimeMin(start.Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		call.TimeMax(end.Format(time.RFC3339))
+	}
+	var ids []string
+	err = call.Pages(r.Context(), func(events *calendar.Events) error {
+		for _, i := range events.Items {
+			ids = append(ids, i.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's events")
+		return
+	}
+	if r.Method == "GET" {
+		respondPreview(w, r, op, len(ids))
+		return
+	}
+
+	res := make([]*batchResult, len(ids))
+	skipped := forEachID(r.Context(), ids, func(idx int, id string) {
+		res[idx] = &batchResult{Index: idx, Status: http.StatusNoContent, ID: id}
+		err := retry(r.Context(), func() error {
+			return srv.Events.Delete(CalendarID, id).Context(r.Context()).Do()
+		})
+		if err != nil {
+			log.Println(err.Error())
+			res[idx].fail(err)
+		}
+	})
+	for _, idx := range skipped {
+		res[idx] = &batchResult{Index: idx, ID: ids[idx]}
+		res[idx].fail(r.Context().Err())
+	}
+	rep := &deleteReport{Results: res}
+	for _, br := range res {
+		if br.Error != "" {
+			rep.Failed++
+		} else {
+			rep.Deleted++
+		}
+	}
+	respond(w, r, http.StatusOK, rep)
+}
+
+// forEachID calls fn for each id with at most bulkConcurrency calls running at
+// once. No more calls are started once ctx is done, the indexes of the ids
+// left out being returned
+func forEachID(ctx context.Context, ids []string, fn func(idx int, id string)) []int {
+	var wg sync.WaitGroup
+	var skipped []int
+	sem := make(chan struct{}, bulkConcurrency)
+	for idx, id := range ids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			skipped = append(skipped, idx)
+			continue
+		}
+		wg.Add(1)
+		go func(idx int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(idx, id)
+		}(idx, id)
+	}
+	wg.Wait()
+	return skipped
+}
+
+// retry calls fn until it succeeds, fails with a non-transient error, or runs
+// out of attempts, backing off between attempts
+func retry(ctx context.Context, fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt < maxAttempts && isTransient(err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt*attempt) * 250 * time.Millisecond):
+		}
+		err = fn()
+	}
+	return err
+}
+
+// isTransient reports whether a Google API error is worth retrying: a server
+// error, or quota and rate limits as respondError tells them apart
+func isTransient(err error) bool {
+	gerr, ok := err.(*googleapi.Error)
+	if !ok {
+		return false
+	}
+	return gerr.Code >= 500 || rateLimited(gerr)
+}
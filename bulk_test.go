@@ -0,0 +1,124 @@
+package calendar
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+)
+
+func TestForEachIDStopsWhenDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ids := make([]string, 20)
+	var calls int32
+	var once sync.Once
+	skipped := forEachID(ctx, ids, func(idx int, id string) {
+		atomic.AddInt32(&calls, 1)
+		once.Do(cancel)
+	})
+	if n := int(atomic.LoadInt32(&calls)) + len(skipped); n != len(ids) {
+		t.Errorf("%d calls and %d skipped, want %d together", calls, len(skipped), len(ids))
+	}
+	if calls > bulkConcurrency+1 {
+		t.Errorf("%d calls started after cancelling, want at most %d", calls, bulkConcurrency+1)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"server error", &googleapi.Error{Code: http.StatusServiceUnavailable}, true},
+		{"too many requests", &googleapi.Error{Code: http.StatusTooManyRequests}, true},
+		{"rate limit", forbidden("userRateLimitExceeded"), true},
+		{"quota", forbidden("quotaExceeded"), true},
+		{"permission", forbidden("forbidden"), false},
+		{"not found", &googleapi.Error{Code: http.StatusNotFound}, false},
+		{"not from google", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.err); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// forbidden returns the 403 Google responds with for reason
+func forbidden(reason string) *googleapi.Error {
+	return &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: reason}}}
+}
+
+func TestBatchResultFailWrapped(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"deadline", &url.Error{Op: "Get", URL: "https://example.com", Err: context.DeadlineExceeded}, http.StatusGatewayTimeout},
+		{"canceled", &url.Error{Op: "Get", URL: "https://example.com", Err: context.Canceled}, http.StatusGatewayTimeout},
+		{"google", forbidden("forbidden"), http.StatusForbidden},
+		{"validation", invalidRequest("bad"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b batchResult
+			b.fail(tt.err)
+			if b.Status != tt.status {
+				t.Errorf("status = %d, want %d", b.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestBulkPatchMixedResults(t *testing.T) {
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/calendars/primary/events/gone":
+			googleError(w, http.StatusNotFound, "notFound")
+		case "/calendars/primary/events/locked":
+			googleError(w, http.StatusForbidden, "forbidden")
+		default:
+			if ev := decodePatch(t, r); ev.ColorId != "2" {
+				t.Errorf("colorId = %q, want 2", ev.ColorId)
+			}
+			writeJSON(w, map[string]string{"id": strings.TrimPrefix(r.URL.Path, "/calendars/primary/events/")})
+		}
+	})
+	body := `{"ids": ["abc", "gone", "locked", "def"], "patch": {"color": "Sage"}}`
+	w := httptest.NewRecorder()
+	BulkPatchEvents(w, httptest.NewRequest("POST", "/events/bulk", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var res batchResponse
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		id     string
+		status int
+	}{{"abc", 200}, {"gone", 404}, {"locked", 403}, {"def", 200}}
+	if len(res.Results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(res.Results), len(want))
+	}
+	for idx, br := range res.Results {
+		if br.Index != idx || br.ID != want[idx].id || br.Status != want[idx].status {
+			t.Errorf("result %d = %+v, want %s with %d", idx, br, want[idx].id, want[idx].status)
+		}
+		if (br.Status != http.StatusOK) != (br.Error != "") {
+			t.Errorf("result %d: status %d with error %q", idx, br.Status, br.Error)
+		}
+	}
+}
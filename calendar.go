@@ -2,30 +2,97 @@ package calendar
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 
 	"github.com/gorilla/mux"
 )
 
 const (
 	tmLabelShort = "2006-01-02"
-	tmLabelLong  = "2006-01-02T15:04:05-07:00"
-	tmShortTime  = "T00:00:00-04:00" // @TODO hardcoded timezone offset is no good. Fix.
+	tmLabelDay   = "20060102"
 )
 
+const maxUpcoming = 250 // most events UpcomingEvents returns, Google's default page size
+
+// eventFields are the event fields always requested when listing events
+var eventFields = []string{"id", "anyoneCanAddSelf", "attachments(title,fileUrl,mimeType,iconLink)", "attendees", "attendeesOmitted", "colorId", "conferenceData",
+	"description", "eventType", "extendedProperties", "hangoutLink", "htmlLink", "iCalUID", "location", "originalStartTime", "recurrence", "recurringEventId", "source", "updated", "start", "end", "status",
+	"summary", "transparency", "visibility", "workingLocationProperties"}
+
+// listFields returns the partial response mask for listing events
+func listFields() googleapi.Field {
+	return googleapi.Field("nextPageToken,nextSyncToken,timeZone,items(" + string(jEventFields()) + ")")
+}
+
+// jEventFields returns the partial response mask of an event converted to a jEvent
+func jEventFields() googleapi.Field {
+	f := append([]string{}, eventFields...)
+	if ListCreator {
+		f = append(f, "creator")
+	}
+	if ListOrganizer {
+		f = append(f, "organizer")
+	}
+	return googleapi.Field(strings.Join(f, ","))
+}
+
 type jEvent struct {
-	ID          string                    `json:"id"`
-	Attendees   []*calendar.EventAttendee `json:"attendees"`
-	AllDay      bool                      `json:"allDayEvent"`
-	ColorBgd    string                    `json:"color"`
-	Date        string                    `json:"date"`
-	Description string                    `json:"description"`
-	Location    string                    `json:"location"`
-	Summary     string                    `json:"summary"`
+	ID                string                    `json:"id"`
+	Attendees         []*calendar.EventAttendee `json:"attendees"`
+	AttendeesOmitted  bool                      `json:"attendeesOmitted"`
+	AllDay            bool                      `json:"allDayEvent"`
+	ColorBgd          string                    `json:"color"`
+	Date              string                    `json:"date"`
+	Description       string                    `json:"description"`
+	Location          string                    `json:"location"`
+	Summary           string                    `json:"summary"`
+	Latitude          *float64                  `json:"latitude,omitempty"`
+	Longitude         *float64                  `json:"longitude,omitempty"`
+	ResponseSummary   *responseSummary          `json:"responseSummary"`
+	Transparency      string                    `json:"transparency"`
+	Status            string                    `json:"status"`
+	ConferenceLink    string                    `json:"conferenceLink"`
+	ICalUID           string                    `json:"iCalUID"`
+	Creator           *calendar.EventCreator    `json:"creator,omitempty"`
+	Organizer         *calendar.EventOrganizer  `json:"organizer,omitempty"`
+	RecurringEventID  string                    `json:"recurringEventId,omitempty"`
+	ColorID           string                    `json:"colorId"`
+	EventType         string                    `json:"eventType"`
+	CalendarID        string                    `json:"calendarId,omitempty"`
+	start             time.Time                 // parsed Date, kept for ordering whatever the output time format
+	Attachments       []jAttachment             `json:"attachments,omitempty"`
+	Visibility        string                    `json:"visibility"`
+	DateAlt           string                    `json:"dateAlt,omitempty"`
+	HTMLLink          string                    `json:"htmlLink"`
+	Source            *eventSource              `json:"source,omitempty"`
+	WorkingLocation   *workingLocation          `json:"workingLocation,omitempty"`
+	StartRaw          string                    `json:"startRaw"`
+	EndRaw            string                    `json:"endRaw"`
+	Undated           bool                      `json:"undated,omitempty"`
+	RecurrenceText    string                    `json:"recurrenceText,omitempty"`
+	AnyoneCanAddSelf  bool                      `json:"anyoneCanAddSelf"`
+	OriginalStartTime string                    `json:"originalStartTime,omitempty"`
+	AttendeeCount     int                       `json:"attendeeCount"`
+}
+
+// jAttachment is a file, usually a Drive doc, attached to an event. Its tags
+// match Google's so it's filled in with the rest of jEvent
+type jAttachment struct {
+	Title    string `json:"title"`
+	FileURL  string `json:"fileUrl"`
+	MimeType string `json:"mimeType"`
+	IconLink string `json:"iconLink"`
 }
 
 func (s *jEvent) setAllDay(flag bool) {
@@ -33,24 +100,66 @@ func (s *jEvent) setAllDay(flag bool) {
 }
 
 type newEvent struct {
-	Color       string
-	Date        string
-	Description string
-	Location    string
-	Summary     string
+	Color            string
+	Date             string
+	Description      string
+	Location         string
+	Summary          string
+	Latitude         *float64
+	Longitude        *float64
+	Transparency     string
+	ICalUID          string
+	Sequence         *int64
+	NoReminders      bool
+	Attendees        []newAttendee
+	Reminders        []newReminder
+	Start            string
+	End              string
+	EventType        string
+	AutoDeclineMode  string
+	DeclineMessage   string
+	ChatStatus       string
+	EndDate          string
+	Days             int
+	Visibility       string
+	Source           *eventSource
+	WorkingLocation  *workingLocation
+	Raw              json.RawMessage
+	AnyoneCanAddSelf *bool
+}
+
+// eventSource is where an event was created from, such as the page in the app that
+// created it, linked from the event in Google Calendar
+type eventSource struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// createdEvent is the response to creating an event. RoomConflicts lists the
+// rooms that declined, being already booked at that time
+type createdEvent struct {
+	ID            string   `json:"id"`
+	RoomConflicts []string `json:"roomConflicts,omitempty"`
 }
 
 var calS CalService
 var srv *calendar.Service
+var srvErr error // why srv couldn't be created, reported by RequireService
 var loc *time.Location
 
 func init() {
 	loc, _ = time.LoadLocation("Local")
-	srv = calS.New()
+	srv, srvErr = calS.Open()
+	if srvErr != nil {
+		log.Println(srvErr.Error())
+	}
 }
 
 // MonthEvents method fetches events for specified month with some overlap
 func MonthEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "MonthEvents")
+	defer span.End()
+
 	// Restrict method to get only
 	if r.Method != "GET" {
 		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
@@ -65,60 +174,600 @@ func MonthEvents(w http.ResponseWriter, r *http.Request) {
 	}
 	dtVar := vars["date"]
 
-	// Set date string to create a time object
-	dtStr := dtVar[0:4] + "-" + dtVar[4:] + "-01"
-	tm, _ := time.ParseInLocation(tmLabelShort, dtStr, loc)
-	// To get some overlap ensuring that the days displayed on a month calendar
-	// also display their respective events, we grab a week before and 2 after
-	startDte := tm.AddDate(0, 0, -7).Format(time.RFC3339)
-	endDte := tm.AddDate(0, 1, 14).Format(time.RFC3339)
-	// Fetch colors so we can display
-	clrs, err := srv.Colors.Get().Do()
+	l, err := tzParam(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	start, end, err := monthRange(dtVar, l)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	listEvents(w, r, start, end)
+}
+
+// monthRange returns the range listed for the YYYYMM month m in location l.
+// To get some overlap ensuring that the days displayed on a month calendar
+// also display their respective events, we grab a week before and 2 after.
+// A 6 week grid shows at most 6 days before the 1st and 14 after the month
+// ends, for a February of 28 days starting on the first grid day. AddDate
+// normalizes, so December runs into January and February's length follows
+// leap years
+func monthRange(m string, l *time.Location) (time.Time, time.Time, error) {
+	// Checked first, as MonthEvents may be routed without the 6 digit pattern
+	if len(m) != 6 {
+		return time.Time{}, time.Time{}, invalidRequest("date must be a YYYYMM month")
+	}
+	tm, err := time.ParseInLocation(tmLabelShort, m[0:4]+"-"+m[4:]+"-01", l)
+	if err != nil {
+		return time.Time{}, time.Time{}, invalidRequest("date must be a YYYYMM month")
+	}
+	return tm.AddDate(0, 0, -7), tm.AddDate(0, 1, 14), nil
+}
+
+// DayEvents method fetches events for the day given as YYYYMMDD, from midnight
+// to midnight in the configured location (or tz). All-day and multi-day events
+// overlapping the day are included
+func DayEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "DayEvents")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	l, err := tzParam(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	vars := mux.Vars(r)
+	day, err := time.ParseInLocation(tmLabelDay, vars["date"], l)
+	if err != nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, date must be YYYYMMDD")
+		return
+	}
+	listEvents(w, r, day, day.AddDate(0, 0, 1))
+}
+
+// RelativeEvents method fetches events for a relative range keyword: today,
+// tomorrow, thisWeek or nextWeek. Ranges are computed in the tz location,
+// and weeks start on Sunday (Google's default week start)
+func RelativeEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "RelativeEvents")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	l, err := tzParam(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	vars := mux.Vars(r)
+	start, end, err := relativeRange(vars["range"], time.Now().In(l))
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	listEvents(w, r, start, end)
+}
+
+// relativeRange returns the [start, end) range for a relative keyword
+func relativeRange(kw string, now time.Time) (time.Time, time.Time, error) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	week := day.AddDate(0, 0, -int(day.Weekday()))
+	switch kw {
+	case "today":
+		return day, day.AddDate(0, 0, 1), nil
+	case "tomorrow":
+		return day.AddDate(0, 0, 1), day.AddDate(0, 0, 2), nil
+	case "thisWeek":
+		return week, week.AddDate(0, 0, 7), nil
+	case "nextWeek":
+		return week.AddDate(0, 0, 7), week.AddDate(0, 0, 14), nil
+	}
+	return time.Time{}, time.Time{}, invalidRequest("range must be today, tomorrow, thisWeek or nextWeek")
+}
 
-	// Fetch events
-	events, err := srv.Events.List("primary").
+// listEvents fetches events between start and end and responds with them
+func listEvents(w http.ResponseWriter, r *http.Request, start, end time.Time) {
+	call := srv.Events.List(CalendarID).
 		ShowDeleted(false).
 		SingleEvents(true).
-		Fields("items(id,attendees,colorId,creator,description,updated,start,summary)").
-		TimeMin(startDte).
-		TimeMax(endDte).
-		OrderBy("startTime").
-		Do()
+		TimeMin(start.Format(time.RFC3339)).
+		TimeMax(end.Format(time.RFC3339)).
+		OrderBy("startTime")
+	respondEvents(w, r, call, 0, start, end)
+}
+
+// errLimit stops paging once enough events have been collected
+var errLimit = errors.New("event limit reached")
+
+// respondEvents runs the list call and responds with the events as jEvents,
+// at most limit of them when limit is positive. start and end are the range
+// the call covers, zero when open, reported by ?envelope=true responses
+func respondEvents(w http.ResponseWriter, r *http.Request, call *calendar.EventsListCall,
+	limit int, start, end time.Time,
+) {
+	apply, err := listParams(r)
 	if err != nil {
-		log.Fatalf("Unable to retrieve next ten of the user's events. %v", err)
-		respondErr(w, r, http.StatusNoContent, "Unable to retrieve user's events")
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	apply(call)
+	filter, err := eventFilter(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
 	}
+	conv, err := eventConverter(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	byDay, err := groupByDay(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	var withTotal, envelope, partial, stream bool
+	for name, p := range map[string]*bool{"total": &withTotal, "envelope": &envelope, "partial": &partial, "stream": &stream} {
+		if v := r.URL.Query().Get(name); v != "" {
+			if *p, err = strconv.ParseBool(v); err != nil {
+				respondErr(w, r, http.StatusBadRequest, "invalid request, "+name+" must be true or false")
+				return
+			}
+		}
+	}
+	if stream && (byDay || withTotal || envelope || partial) {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, group, total, envelope and partial can't be used with stream")
+		return
+	}
+	l, err := tzParam(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// Fetch colors so we can display
+	if err := eventColors.refresh(r.Context()); err != nil {
+		log.Println(err.Error())
+	}
+	setCanEdit(w, r)
+
+	if stream {
+		streamEvents(w, r, call, limit, filter, conv)
+		return
+	}
+
+	// Fetch events, all pages of them. Google can return an empty page that
+	// still has a nextPageToken, so paging goes on for as long as there is a
+	// token rather than stopping at the first page without items
 	res := []*jEvent{}
-	if len(events.Items) > 0 {
+	var days []string
+	var tz, syncToken string
+	pages := 0
+	err = call.Fields(listFields()).Pages(r.Context(), func(events *calendar.Events) error {
+		pages++
+		tz = events.TimeZone
+		syncToken = events.NextSyncToken
 		for _, i := range events.Items {
-			ev := &jEvent{}
-			res1, _ := json.Marshal(i)
-			// Set color
-			ev.ColorBgd = clrs.Event[i.ColorId].Background
-
-			// If the DateTime is an empty string the Event is an all-day Event.
-			// Formatting date the same with allDayEvent flag allows end-user
-			// the option of how to handle
-			if i.Start.DateTime != "" {
-				ts, _ := time.Parse(tmLabelLong, i.Start.DateTime)
-				ev.Date = ts.Format(time.RFC3339)
-				ev.setAllDay(false)
-			} else {
-				// To keep things simple for the js date interpretation, we're formatting all day event
-				// dates the same as a DateTime (above)
-				ts, _ := time.Parse(tmLabelLong, i.Start.Date+tmShortTime)
-				ev.Date = ts.Format(time.RFC3339)
-				ev.setAllDay(true)
+			if !filter(i) {
+				continue
+			}
+			res = append(res, conv(i))
+			days = append(days, startDay(i, l))
+			// Stopping here rather than at the next event saves fetching
+			// another page only to find the limit was already reached
+			if limit > 0 && len(res) >= limit {
+				return errLimit
+			}
+		}
+		return nil
+	})
+	// With partial=true the pages fetched before a failure are kept, the
+	// envelope saying the listing was truncated
+	var truncated error
+	if err != nil && err != errLimit {
+		log.Println(err.Error())
+		if !partial || pages == 0 {
+			respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's events")
+			return
+		}
+		truncated = err
+	}
+	limited := err == errLimit
+
+	var out interface{} = res
+	if byDay {
+		grouped := map[string][]*jEvent{}
+		for idx, ev := range res {
+			grouped[days[idx]] = append(grouped[days[idx]], ev)
+		}
+		out = grouped
+	}
+	if withTotal || envelope || truncated != nil {
+		env := &eventsEnvelope{Events: out}
+		if truncated != nil {
+			env.Truncated = true
+			env.Error = truncated.Error()
+		}
+		if envelope {
+			count := len(res)
+			env.Range = &envelopeRange{}
+			if !start.IsZero() {
+				env.Range.Start = start.In(l).Format(time.RFC3339)
+			}
+			if !end.IsZero() {
+				env.Range.End = end.In(l).Format(time.RFC3339)
+			}
+			env.TimeZone = tz
+			env.Count = &count
+			if !limited && truncated == nil && !listFiltered(r) {
+				env.NextSyncToken = syncToken
+			}
+		}
+		if withTotal && truncated == nil {
+			total := len(res)
+			if limited {
+				if total, err = countEvents(r, call, filter, start, end); err != nil {
+					log.Println(err.Error())
+					respondError(w, r, http.StatusInternalServerError, err, "Unable to count user's events")
+					return
+				}
+			}
+			env.Total = &total
+		}
+		out = env
+	}
+	respondETag(w, r, http.StatusOK, out)
+}
+
+// eventsEnvelope wraps listed events, as an array or grouped, with what
+// describes them. Range, TimeZone and Count, for ?envelope=true, are the
+// effective range and time zone Google used and how many events are returned.
+// Total, for ?total=true, is how many events match, beyond those returned,
+// counting no further than MaxRangeSpan ahead when the range is open ended.
+//
+// NextSyncToken lets clients switch to incremental sync from a listing. Google
+// only gives it on the last page, and it stands for the whole calendar rather
+// than what was returned, so it's only present for a full listing: one not cut
+// short by a limit and without filters, whether Google's or applied after
+// fetching.
+//
+// Truncated, for ?partial=true, is set when fetching a page failed after
+// others succeeded, Error saying why. Total is then left out
+type eventsEnvelope struct {
+	Truncated     bool           `json:"truncated,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	Range         *envelopeRange `json:"range,omitempty"`
+	TimeZone      string         `json:"timeZone,omitempty"`
+	Count         *int           `json:"count,omitempty"`
+	Total         *int           `json:"total,omitempty"`
+	NextSyncToken string         `json:"nextSyncToken,omitempty"`
+	Events        interface{}    `json:"events"`
+}
+
+// envelopeRange is the range listed, an end being empty when it's open
+type envelopeRange struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// maxCountPage is the largest page Google lists, used by the count pass
+const maxCountPage = 2500
+
+// countEvents counts every event matching filter in a second pass over the
+// list call, requesting only what filter needs, for ?total=true responses
+// cut short by a limit. A range open at the end, as for upcoming events, is
+// only counted up to MaxRangeSpan past its start
+func countEvents(r *http.Request, call *calendar.EventsListCall, filter func(*calendar.Event) bool,
+	start, end time.Time,
+) (int, error) {
+	if end.IsZero() {
+		if start.IsZero() {
+			start = time.Now()
+		}
+		call.TimeMax(start.Add(MaxRangeSpan).Format(time.RFC3339))
+	}
+	n := 0
+	err := call.MaxResults(maxCountPage).Fields("nextPageToken,items("+filterFields+")").Pages(r.Context(), func(events *calendar.Events) error {
+		for _, i := range events.Items {
+			if filter(i) {
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// groupByDay parses the group query param, where group=day keys the events by
+// their start day rather than returning a flat array
+func groupByDay(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("group") {
+	case "":
+		return false, nil
+	case "day":
+		return true, nil
+	}
+	return false, invalidRequest("group must be day")
+}
+
+// startDay returns the YYYY-MM-DD day event i starts on in location l. An
+// event spanning midnight is only under the day it starts, and one with no
+// start, e.g. cancelled, is under the empty day
+func startDay(i *calendar.Event, l *time.Location) string {
+	switch {
+	case i.Start == nil:
+		return ""
+	case i.Start.DateTime != "":
+		ts, err := time.Parse(time.RFC3339, i.Start.DateTime)
+		if err != nil {
+			return ""
+		}
+		return ts.In(l).Format(tmLabelShort)
+	}
+	return i.Start.Date
+}
+
+// streamEvents writes the events as a JSON array an element at a time,
+// flushing after each page from Google, so memory stays bounded however large
+// the range. Once writing has started an error can only be logged, which
+// leaves the array unterminated for the client to detect
+func streamEvents(w http.ResponseWriter, r *http.Request, call *calendar.EventsListCall,
+	limit int, filter func(*calendar.Event) bool, conv func(*calendar.Event) *jEvent,
+) {
+	flusher, _ := w.(http.Flusher)
+	n := 0
+	started := false
+	err := call.Fields(listFields()).Pages(r.Context(), func(events *calendar.Events) error {
+		if !started {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, "[")
+			started = true
+		}
+		for _, i := range events.Items {
+			if !filter(i) {
+				continue
+			}
+			b, err := encodeJSON(r, conv(i))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				io.WriteString(w, ",")
+			}
+			w.Write(b)
+			n++
+			if limit > 0 && n >= limit {
+				return errLimit
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil && err != errLimit {
+		log.Println(err.Error())
+		if !started {
+			respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's events")
+		}
+		return
+	}
+	io.WriteString(w, "]")
+}
+
+// UpdatedEvents method fetches events modified after the updatedMin query param
+// (RFC3339), including cancelled ones, ordered by modification time. Events
+// aren't expanded with SingleEvents, so a change to a recurring series is
+// returned once as its master event and changed instances as exceptions
+func UpdatedEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UpdatedEvents")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	tm, err := time.Parse(time.RFC3339, r.URL.Query().Get("updatedMin"))
+	if err != nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, updatedMin must be an RFC3339 timestamp")
+		return
+	}
+	if tm.After(time.Now()) {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, updatedMin is in the future")
+		return
+	}
+
+	call := srv.Events.List(CalendarID).
+		ShowDeleted(true).
+		UpdatedMin(tm.Format(time.RFC3339)).
+		OrderBy("updated")
+	respondEvents(w, r, call, 0, time.Time{}, time.Time{})
+}
+
+// UpcomingEvents method fetches the next events from now, count of them
+// (default 10, at most maxUpcoming)
+func UpcomingEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UpcomingEvents")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	count := 10
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxUpcoming {
+			respondErr(w, r, http.StatusBadRequest, fmt.Sprintf("invalid request, count must be between 1 and %d", maxUpcoming))
+			return
+		}
+		count = n
+	}
+
+	now := time.Now()
+	call := srv.Events.List(CalendarID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(now.Format(time.RFC3339)).
+		MaxResults(int64(count)).
+		OrderBy("startTime")
+	respondEvents(w, r, call, count, now, time.Time{})
+}
+
+// toJEvent converts a calendar.Event to the jEvent we respond with, all-day
+// dates being stamped as midnight in location l
+func toJEvent(i *calendar.Event, clrs *colorCache, l *time.Location) *jEvent {
+	ev := &jEvent{}
+	res1, _ := json.Marshal(i)
+	// Set color
+	ev.ColorBgd, _ = clrs.Background(i.ColorId)
+	// Along with the id, so clients can resubmit the same color
+	ev.ColorID = i.ColorId
+
+	// If the DateTime is an empty string the Event is an all-day Event.
+	// Formatting date the same with allDayEvent flag allows end-user
+	// the option of how to handle
+	switch {
+	case i.Start == nil:
+		// Cancelled events may come back with no start at all
+		ev.Undated = true
+	case i.Start.DateTime != "":
+		ts, _ := time.Parse(time.RFC3339, i.Start.DateTime)
+		ev.Date = ts.Format(time.RFC3339)
+		ev.start = ts
+		ev.setAllDay(false)
+	default:
+		// To keep things simple for the js date interpretation, we're formatting all day event
+		// dates the same as a DateTime (above). The offset is l's on that date,
+		// so it follows DST
+		ts, _ := time.ParseInLocation(tmLabelShort, i.Start.Date, l)
+		ev.Date = ts.Format(time.RFC3339)
+		ev.start = ts
+		ev.setAllDay(true)
+	}
+	json.Unmarshal(res1, &ev)
+	ev.Latitude, ev.Longitude = getGeo(i)
+	ev.ResponseSummary = summarizeResponses(i.Attendees)
+	ev.AttendeeCount = ev.ResponseSummary.Headcount()
+	ev.ConferenceLink = conferenceLink(i)
+	ev.ICalUID = i.ICalUID
+	ev.RecurringEventID = i.RecurringEventId
+	ev.EventType = i.EventType
+	ev.WorkingLocation = toWorkingLocation(i.WorkingLocationProperties)
+	ev.HTMLLink = i.HtmlLink
+	// As Google gave them, for clients needing the original offsets
+	ev.StartRaw, ev.EndRaw = rawDateTime(i.Start), rawDateTime(i.End)
+	// Where a recurring instance was before being moved, if it was
+	ev.OriginalStartTime = rawDateTime(i.OriginalStartTime)
+	if i.End == nil {
+		ev.Undated = true
+	}
+	ev.Creator = i.Creator
+	ev.Organizer = i.Organizer
+	// Google omits transparency for the default, busy, events
+	if ev.Transparency == "" {
+		ev.Transparency = "opaque"
+	}
+	// And visibility for those following the calendar's default
+	if ev.Visibility == "" {
+		ev.Visibility = "default"
+	}
+	return ev
+}
+
+// startsInPast reports whether evt starts before now, less PastEventGrace. An
+// all-day event is in the past from the day after its date in now's location
+func startsInPast(evt *calendar.Event, now time.Time) bool {
+	if evt.Start == nil {
+		return false
+	}
+	if evt.Start.Date != "" {
+		day, err := time.ParseInLocation(tmLabelShort, evt.Start.Date, now.Location())
+		return err == nil && !now.Before(day.AddDate(0, 0, 1))
+	}
+	st, err := time.Parse(time.RFC3339, evt.Start.DateTime)
+	return err == nil && st.Before(now.Add(-PastEventGrace))
+}
+
+// visibilities are the event visibilities Google accepts. Private and
+// confidential hide the details from others the calendar is shared with
+var visibilities = []string{"default", "public", "private", "confidential"}
+
+// rawDateTime returns the dateTime, or date for all-day events, of edt
+func rawDateTime(edt *calendar.EventDateTime) string {
+	if edt == nil {
+		return ""
+	}
+	if edt.DateTime != "" {
+		return edt.DateTime
+	}
+	return edt.Date
+}
+
+// allDayEnd returns the exclusive end date Google requires for an all-day
+// event from Date through EndDate (inclusive), or lasting Days days. With
+// neither set the event lasts the one day
+func allDayEnd(s *newEvent) (string, error) {
+	st, err := time.Parse(tmLabelShort, s.Date)
+	if err != nil {
+		return "", invalidRequest("date must be YYYY-MM-DD")
+	}
+	days := 1
+	switch {
+	case s.EndDate != "" && s.Days != 0:
+		return "", invalidRequest("endDate and days can't both be set")
+	case s.EndDate != "":
+		end, err := time.Parse(tmLabelShort, s.EndDate)
+		if err != nil {
+			return "", invalidRequest("endDate must be YYYY-MM-DD")
+		}
+		if end.Before(st) {
+			return "", invalidRequest("endDate can't be before date")
+		}
+		days = int(end.Sub(st).Hours()/24) + 1
+	case s.Days < 0:
+		return "", invalidRequest("days must be positive")
+	case s.Days > 0:
+		days = s.Days
+	}
+	return st.AddDate(0, 0, days).Format(tmLabelShort), nil
+}
+
+// conferenceLink returns the video entry point of the event's conference, or its hangout link
+func conferenceLink(i *calendar.Event) string {
+	if i.ConferenceData != nil {
+		for _, ep := range i.ConferenceData.EntryPoints {
+			if ep.EntryPointType == "video" {
+				return ep.Uri
 			}
-			json.Unmarshal(res1, &ev)
-			res = append(res, ev)
 		}
 	}
-	respond(w, r, http.StatusOK, res)
+	return i.HangoutLink
 }
 
 // Event method - Redirect event request to appropriate method
 func Event(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "Event")
+	defer span.End()
+
 	switch r.Method {
 	case "GET":
 		fetchEvent(w, r)
@@ -134,12 +783,36 @@ func Event(w http.ResponseWriter, r *http.Request) {
 func fetchEvent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	eID := vars["id"]
-	ev, err := srv.Events.Get("primary", eID).Do()
+	n, err := maxAttendees(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := tzParam(r); err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	call := srv.Events.Get(CalendarID, eID)
+	if n > 0 {
+		call.MaxAttendees(n)
+	}
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		call.TimeZone(tz)
+	}
+	// Google gives 404 for ids that never existed and 410 for deleted events,
+	// which sync clients handle differently, so both are passed on
+	ev, err := call.Context(r.Context()).Do()
 	if err != nil {
-		log.Fatalf("Unable to retrieve event. %v", err)
-		respondErr(w, r, http.StatusNotFound)
+		log.Println(err.Error())
+		respondError(w, r, http.StatusNotFound, err, "Unable to retrieve event")
+		return
+	}
+	// Deleted events can also still be fetched, cancelled
+	if ev.Status == "cancelled" {
+		respondHTTPErr(w, r, http.StatusGone)
 		return
 	}
+	setCanEdit(w, r)
 	respond(w, r, http.StatusOK, ev)
 }
 
@@ -154,14 +827,51 @@ func createEvent(w http.ResponseWriter, r *http.Request) {
 	}
 	r.Body.Close()
 
-	evt := assembleEvent(&newEv)
-	ev, err := srv.Events.Insert("primary", evt).Fields("id").Do()
+	if newEv.Color == "" && DefaultColor != "" {
+		id, err := colorID(DefaultColor)
+		if err != nil {
+			log.Println("DefaultColor: " + err.Error())
+			respondErr(w, r, http.StatusInternalServerError, "invalid DefaultColor configured")
+			return
+		}
+		newEv.Color = id
+	}
+	evt, err := assembleEvent(&newEv)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if RejectPastEvents && startsInPast(evt, time.Now().In(loc)) {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, event starts in the past")
+		return
+	}
+	s, err := serviceFor(r)
 	if err != nil {
 		log.Println(err.Error())
-		respondErr(w, r, http.StatusInternalServerError, err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
 		return
 	}
-	respond(w, r, http.StatusCreated, ev)
+
+	// With an iCalUID we import rather than insert, keeping the iCalUID so
+	// re-imports update the same event instead of creating duplicates
+	var ev *calendar.Event
+	if newEv.ICalUID != "" {
+		if err := validICalUID(newEv.ICalUID); err != nil {
+			respondError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		evt.ICalUID = newEv.ICalUID
+		ev, err = s.Events.Import(CalendarID, evt).Fields("id,attendees").Context(r.Context()).Do()
+	} else {
+		ev, err = s.Events.Insert(CalendarID, evt).Fields("id,attendees").Context(r.Context()).Do()
+	}
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", eventURL(ev.Id))
+	respond(w, r, http.StatusCreated, &createdEvent{ID: ev.Id, RoomConflicts: roomConflicts(ev.Attendees)})
 }
 
 func updateEvent(w http.ResponseWriter, r *http.Request) {
@@ -173,25 +883,89 @@ func updateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	eID := vars["id"]
+	single, err := singleScope(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// Submitted attendees replace the event's by default, as PATCH does for
+	// any list. attendeeMode=merge instead adds them to the current attendees,
+	// keeping their responses
+	merge := false
+	switch r.URL.Query().Get("attendeeMode") {
+	case "", "replace":
+	case "merge":
+		merge = true
+	default:
+		respondErr(w, r, http.StatusBadRequest, "invalid request, attendeeMode must be replace or merge")
+		return
+	}
 
 	// extract submitted event from request body and decode to newEvent struct
-	err := json.NewDecoder(r.Body).Decode(&pEv)
+	err = json.NewDecoder(r.Body).Decode(&pEv)
 	if err != nil {
 		log.Println(err.Error())
 	}
 	r.Body.Close()
 
 	// Extract data from newEvent to populate the calendar.Event struct
-	evt := assembleEvent(&pEv)
-	ev, err := srv.Events.Patch("primary", eID, evt).Fields("id").Do()
+	evt, err := assembleEvent(&pEv)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	merge = merge && evt.Attendees != nil
+	var cur *calendar.Event
+	if single || merge || pEv.Sequence == nil {
+		cur, err = srv.Events.Get(CalendarID, eID).Fields("attendees,recurringEventId,sequence").Context(r.Context()).Do()
+		if err != nil {
+			log.Println(err.Error())
+			respondError(w, r, http.StatusNotFound, err)
+			return
+		}
+	}
+	// Patching an instance id makes Google create an exception for just that
+	// occurrence, so scope=single only has to check the id is an instance
+	if single && cur.RecurringEventId == "" {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, scope single needs a recurring event instance")
+		return
+	}
+	if merge {
+		attendees := cur.Attendees
+		for _, a := range evt.Attendees {
+			if !hasAttendee(attendees, a.Email) {
+				attendees = append(attendees, a)
+			}
+		}
+		evt.Attendees = attendees
+	}
+
+	// Attendees' calendar clients use the sequence to recognize a new version
+	// of the invitation, so take the one submitted or bump the current one
+	if pEv.Sequence != nil {
+		if *pEv.Sequence < 0 {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, sequence must not be negative")
+			return
+		}
+		evt.Sequence = *pEv.Sequence
+	} else {
+		evt.Sequence = cur.Sequence + 1
+	}
+	evt.ForceSendFields = append(evt.ForceSendFields, "Sequence")
+
+	ev, err := srv.Events.Patch(CalendarID, eID, evt).Fields("id").Context(r.Context()).Do()
 	if err != nil {
 		log.Println(err.Error())
-		respondErr(w, r, http.StatusInternalServerError, err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	respond(w, r, http.StatusOK, ev)
 }
 
+// deleteEvent permanently deletes the event, which for a recurring master
+// deletes the whole series. With ?mode=cancel a single recurring instance is
+// instead patched to cancelled, leaving the master and other instances intact
 func deleteEvent(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	if vars["id"] == "" {
@@ -199,26 +973,90 @@ func deleteEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := srv.Events.Delete("primary", vars["id"]).Do()
+	switch r.URL.Query().Get("mode") {
+	case "", "delete":
+	case "cancel":
+		cancelInstance(w, r, vars["id"])
+		return
+	default:
+		respondErr(w, r, http.StatusBadRequest, "invalid request, mode must be delete or cancel")
+		return
+	}
+
+	err := srv.Events.Delete(CalendarID, vars["id"]).Context(r.Context()).Do()
 	if err != nil {
 		log.Println(err.Error())
-		respondErr(w, r, http.StatusInternalServerError, err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	respond(w, r, http.StatusOK, true)
+}
+
+// cancelInstance sets a recurring instance's status to cancelled
+func cancelInstance(w http.ResponseWriter, r *http.Request, eID string) {
+	ev, err := srv.Events.Get(CalendarID, eID).Fields("recurringEventId").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if ev.RecurringEventId == "" {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, only recurring event instances can be cancelled")
+		return
+	}
+
+	_, err = srv.Events.Patch(CalendarID, eID, &calendar.Event{Status: "cancelled"}).Fields("id").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 	respond(w, r, http.StatusOK, true)
 }
 
 // Helper method to assemble event data
-func assembleEvent(s *newEvent) *calendar.Event {
+func assembleEvent(s *newEvent) (*calendar.Event, error) {
 	evt := &calendar.Event{}
 	if s.Date != "" {
+		end, err := allDayEnd(s)
+		if err != nil {
+			return nil, err
+		}
 		evt.Start = &calendar.EventDateTime{Date: s.Date}
-		evt.End = &calendar.EventDateTime{Date: s.Date}
+		evt.End = &calendar.EventDateTime{Date: end}
+	} else if s.EndDate != "" || s.Days != 0 {
+		return nil, invalidRequest("endDate and days need date")
+	}
+	if s.Start != "" || s.End != "" {
+		// A timed event, RFC3339 start and end
+		if s.Date != "" {
+			return nil, invalidRequest("date can't be set with start and end")
+		}
+		st, err := time.Parse(time.RFC3339, s.Start)
+		if err != nil {
+			return nil, invalidRequest("start must be RFC3339")
+		}
+		end, err := time.Parse(time.RFC3339, s.End)
+		if err != nil {
+			return nil, invalidRequest("end must be RFC3339")
+		}
+		if !end.After(st) {
+			return nil, invalidRequest("end must be after start")
+		}
+		evt.Start = &calendar.EventDateTime{DateTime: s.Start}
+		evt.End = &calendar.EventDateTime{DateTime: s.End}
 	}
 	if s.Color != "" {
-		evt.ColorId = s.Color
+		id, err := colorID(s.Color)
+		if err != nil {
+			return nil, err
+		}
+		evt.ColorId = id
 	}
 	if s.Description != "" {
+		if len(s.Description) > MaxDescriptionLength {
+			return nil, invalidRequest(fmt.Sprintf("description is longer than %d bytes", MaxDescriptionLength))
+		}
 		evt.Description = s.Description
 	}
 	if s.Location != "" {
@@ -227,5 +1065,65 @@ func assembleEvent(s *newEvent) *calendar.Event {
 	if s.Summary != "" {
 		evt.Summary = s.Summary
 	}
-	return evt
+	if s.Transparency != "" {
+		if s.Transparency != "opaque" && s.Transparency != "transparent" {
+			return nil, invalidRequest("transparency must be opaque or transparent")
+		}
+		evt.Transparency = s.Transparency
+	}
+	// Letting anyone add themselves doesn't change what guests may do once
+	// added, which follows the event's guest permissions as set in Google.
+	// Sent when false too, so a PATCH can turn it off
+	if s.AnyoneCanAddSelf != nil {
+		evt.AnyoneCanAddSelf = *s.AnyoneCanAddSelf
+		evt.ForceSendFields = append(evt.ForceSendFields, "AnyoneCanAddSelf")
+	}
+	if s.Source != nil {
+		u, err := url.Parse(s.Source.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, invalidRequest("source url must be an http or https URL")
+		}
+		evt.Source = &calendar.EventSource{Title: s.Source.Title, Url: s.Source.URL}
+	}
+	if s.Visibility != "" {
+		if !contains(visibilities, s.Visibility) {
+			return nil, invalidRequest("visibility must be among " + strings.Join(visibilities, ", "))
+		}
+		evt.Visibility = s.Visibility
+	}
+	if s.Attendees != nil {
+		attendees, err := assembleAttendees(s.Attendees)
+		if err != nil {
+			return nil, err
+		}
+		evt.Attendees = attendees
+	}
+	if s.Reminders != nil {
+		if s.NoReminders {
+			return nil, invalidRequest("reminders can't be set with noReminders")
+		}
+		rems, err := assembleReminders(s.Reminders)
+		if err != nil {
+			return nil, err
+		}
+		evt.Reminders = rems
+	}
+	if s.NoReminders {
+		// Without an explicit empty override list the calendar's default reminders apply
+		evt.Reminders = &calendar.EventReminders{
+			UseDefault:      false,
+			Overrides:       []*calendar.EventReminder{},
+			ForceSendFields: []string{"UseDefault", "Overrides"},
+		}
+	}
+	if err := setEventType(evt, s); err != nil {
+		return nil, err
+	}
+	if err := setGeo(evt, s.Latitude, s.Longitude); err != nil {
+		return nil, err
+	}
+	if s.Raw != nil {
+		return mergeRaw(evt, s.Raw)
+	}
+	return evt, nil
 }
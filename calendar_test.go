@@ -0,0 +1,677 @@
+package calendar
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestRelativeRange(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A Wednesday morning in Tokyo, still Tuesday in UTC
+	now := time.Date(2024, 1, 10, 8, 0, 0, 0, tokyo)
+	tests := []struct {
+		kw         string
+		start, end string
+	}{
+		{"today", "2024-01-10", "2024-01-11"},
+		{"tomorrow", "2024-01-11", "2024-01-12"},
+		{"thisWeek", "2024-01-07", "2024-01-14"},
+		{"nextWeek", "2024-01-14", "2024-01-21"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kw, func(t *testing.T) {
+			start, end, err := relativeRange(tt.kw, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				got  time.Time
+				want string
+			}{{start, tt.start}, {end, tt.end}} {
+				want, _ := time.ParseInLocation(tmLabelShort, c.want, tokyo)
+				if !c.got.Equal(want) {
+					t.Errorf("got %s, want %s", c.got, want)
+				}
+			}
+		})
+	}
+	if _, _, err := relativeRange("yesterday", now); err == nil {
+		t.Error("want an error for an unknown keyword")
+	}
+}
+
+func TestMonthRange(t *testing.T) {
+	tests := []struct {
+		month      string
+		start, end string
+	}{
+		{"202402", "2024-01-25", "2024-03-15"}, // leap year February
+		{"202302", "2023-01-25", "2023-03-15"},
+		{"202412", "2024-11-24", "2025-01-15"}, // into the next year
+		{"202501", "2024-12-25", "2025-02-15"}, // from the previous year
+	}
+	for _, tt := range tests {
+		t.Run(tt.month, func(t *testing.T) {
+			start, end, err := monthRange(tt.month, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := start.Format(tmLabelShort); got != tt.start {
+				t.Errorf("start = %s, want %s", got, tt.start)
+			}
+			if got := end.Format(tmLabelShort); got != tt.end {
+				t.Errorf("end = %s, want %s", got, tt.end)
+			}
+
+			// The range covers a 6 week grid starting on the Sunday on or before the 1st
+			first, _ := time.Parse(tmLabelDay, tt.month+"01")
+			grid := first.AddDate(0, 0, -int(first.Weekday()))
+			if grid.Before(start) || grid.AddDate(0, 0, 42).After(end) {
+				t.Errorf("range %s to %s doesn't cover the grid from %s", start, end, grid)
+			}
+		})
+	}
+}
+
+func TestMonthRangeInvalid(t *testing.T) {
+	for _, m := range []string{"", "202", "2024", "2024011", "202413", "abcdef"} {
+		if _, _, err := monthRange(m, time.UTC); err == nil {
+			t.Errorf("%q: want an error", m)
+		}
+	}
+}
+
+// runUpdate sends body to updateEvent for event abc, which Google holds as
+// cur, returning the response and the event patched, nil when none was
+func runUpdate(t *testing.T, query, body string, cur *calendar.Event) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	var patched map[string]interface{}
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/calendars/primary/events/abc":
+			writeJSON(w, cur)
+		case r.Method == "PATCH" && r.URL.Path == "/calendars/primary/events/abc":
+			if err := json.NewDecoder(r.Body).Decode(&patched); err != nil {
+				t.Error(err)
+			}
+			writeJSON(w, &calendar.Event{Id: "abc"})
+		default:
+			googleError(w, http.StatusNotFound, "notFound")
+		}
+	})
+	r := httptest.NewRequest("PATCH", "/event/abc"+query, strings.NewReader(body))
+	r = mux.SetURLVars(r, map[string]string{"id": "abc"})
+	w := httptest.NewRecorder()
+	updateEvent(w, r)
+	return w, patched
+}
+
+func TestUpdateEventSequence(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want float64
+	}{
+		{"bumped", `{"summary": "Moved"}`, 4},
+		{"submitted", `{"summary": "Moved", "sequence": 7}`, 7},
+		{"submitted zero", `{"summary": "Moved", "sequence": 0}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, patched := runUpdate(t, "", tt.body, &calendar.Event{Id: "abc", Sequence: 3})
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			seq, ok := patched["sequence"].(float64)
+			if !ok || seq != tt.want {
+				t.Errorf("sequence = %v, want %v", patched["sequence"], tt.want)
+			}
+		})
+	}
+
+	w, patched := runUpdate(t, "", `{"sequence": -1}`, &calendar.Event{Id: "abc", Sequence: 3})
+	if w.Code != http.StatusBadRequest || patched != nil {
+		t.Errorf("negative sequence: status = %d, patched = %v", w.Code, patched)
+	}
+}
+
+func TestJEventFieldsCreatorOrganizer(t *testing.T) {
+	defer func(c, o bool) { ListCreator, ListOrganizer = c, o }(ListCreator, ListOrganizer)
+	i := &calendar.Event{
+		Id:        "abc",
+		Start:     &calendar.EventDateTime{DateTime: "2024-01-10T15:00:00Z"},
+		End:       &calendar.EventDateTime{DateTime: "2024-01-10T16:00:00Z"},
+		Creator:   &calendar.EventCreator{Email: "creator@example.com"},
+		Organizer: &calendar.EventOrganizer{Email: "organizer@example.com"},
+	}
+	for _, tt := range []struct{ creator, organizer bool }{{false, false}, {true, false}, {false, true}, {true, true}} {
+		ListCreator, ListOrganizer = tt.creator, tt.organizer
+		fields := strings.Split(string(jEventFields()), ",")
+		if got := contains(fields, "creator"); got != tt.creator {
+			t.Errorf("ListCreator %v: creator requested = %v", tt.creator, got)
+		}
+		if got := contains(fields, "organizer"); got != tt.organizer {
+			t.Errorf("ListOrganizer %v: organizer requested = %v", tt.organizer, got)
+		}
+	}
+
+	// What the mask requests must make it into the jEvent
+	ev := toJEvent(i, nil, time.UTC)
+	if ev.Creator == nil || ev.Creator.Email != "creator@example.com" {
+		t.Errorf("creator = %+v", ev.Creator)
+	}
+	if ev.Organizer == nil || ev.Organizer.Email != "organizer@example.com" {
+		t.Errorf("organizer = %+v", ev.Organizer)
+	}
+}
+
+func TestAllDayEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		ev   newEvent
+		want string
+	}{
+		{"one day", newEvent{Date: "2024-01-10"}, "2024-01-11"},
+		{"three days by end date", newEvent{Date: "2024-01-10", EndDate: "2024-01-12"}, "2024-01-13"},
+		{"three days by count", newEvent{Date: "2024-01-10", Days: 3}, "2024-01-13"},
+		{"three days over a leap day", newEvent{Date: "2024-02-28", Days: 3}, "2024-03-02"},
+		{"three days into next year", newEvent{Date: "2024-12-30", EndDate: "2025-01-01"}, "2025-01-02"},
+		{"end date same day", newEvent{Date: "2024-01-10", EndDate: "2024-01-10"}, "2024-01-11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := allDayEnd(&tt.ev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("end = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, ev := range []newEvent{
+		{Date: "2024-01-10", EndDate: "2024-01-09"},
+		{Date: "2024-01-10", EndDate: "2024-01-12", Days: 3},
+		{Date: "2024-01-10", Days: -3},
+		{Date: "10/01/2024"},
+	} {
+		if _, err := allDayEnd(&ev); err == nil {
+			t.Errorf("%+v: want an error", ev)
+		}
+	}
+}
+
+func TestAssembleEventAllDaySpan(t *testing.T) {
+	evt, err := assembleEvent(&newEvent{Summary: "Offsite", Date: "2024-01-10", Days: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Start.Date != "2024-01-10" || evt.End.Date != "2024-01-13" {
+		t.Errorf("dates = %s to %s, want 2024-01-10 to 2024-01-13", evt.Start.Date, evt.End.Date)
+	}
+	if evt.Start.DateTime != "" || evt.End.DateTime != "" {
+		t.Error("all-day event has times")
+	}
+}
+
+func TestUpdateEventSingleInstance(t *testing.T) {
+	const inst = "abc_20240110T150000Z"
+	var patches []string
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/calendars/primary/events/"+inst:
+			writeJSON(w, &calendar.Event{Id: inst, RecurringEventId: "abc", Sequence: 1})
+		case r.Method == "GET" && r.URL.Path == "/calendars/primary/events/abc":
+			writeJSON(w, &calendar.Event{Id: "abc", Recurrence: []string{"RRULE:FREQ=WEEKLY"}})
+		case r.Method == "PATCH":
+			patches = append(patches, r.URL.Path)
+			if ev := decodePatch(t, r); ev.Start == nil || ev.Start.DateTime != "2024-01-11T15:00:00Z" {
+				t.Errorf("start = %+v", ev.Start)
+			}
+			writeJSON(w, &calendar.Event{Id: strings.TrimPrefix(r.URL.Path, "/calendars/primary/events/")})
+		default:
+			googleError(w, http.StatusNotFound, "notFound")
+		}
+	})
+	update := func(id string) *httptest.ResponseRecorder {
+		body := `{"start": "2024-01-11T15:00:00Z", "end": "2024-01-11T16:00:00Z"}`
+		r := httptest.NewRequest("PATCH", "/event/"+id+"?scope=single", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		updateEvent(w, mux.SetURLVars(r, map[string]string{"id": id}))
+		return w
+	}
+
+	if w := update(inst); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if len(patches) != 1 || patches[0] != "/calendars/primary/events/"+inst {
+		t.Errorf("patched %v, want only the instance", patches)
+	}
+
+	// The master isn't an instance, so isn't patched at all
+	patches = nil
+	if w := update("abc"); w.Code != http.StatusBadRequest {
+		t.Errorf("master: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if len(patches) != 0 {
+		t.Errorf("master: patched %v", patches)
+	}
+}
+
+func TestStartsInPast(t *testing.T) {
+	defer func(g time.Duration) { PastEventGrace = g }(PastEventGrace)
+	PastEventGrace = 5 * time.Minute
+	tor, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, tor)
+	timed := func(d time.Duration) *calendar.Event {
+		return &calendar.Event{Start: &calendar.EventDateTime{DateTime: now.Add(d).Format(time.RFC3339)}}
+	}
+	allDay := func(date string) *calendar.Event {
+		return &calendar.Event{Start: &calendar.EventDateTime{Date: date}}
+	}
+	tests := []struct {
+		name string
+		evt  *calendar.Event
+		want bool
+	}{
+		{"future", timed(time.Hour), false},
+		{"now", timed(0), false},
+		{"within grace", timed(-4 * time.Minute), false},
+		{"at grace", timed(-5 * time.Minute), false},
+		{"past grace", timed(-5*time.Minute - time.Second), true},
+		{"today", allDay("2024-01-10"), false},
+		{"yesterday", allDay("2024-01-09"), true},
+		{"tomorrow", allDay("2024-01-11"), false},
+		{"no start", &calendar.Event{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := startsInPast(tt.evt, now); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// An all-day event is today until midnight where now is
+	if startsInPast(allDay("2024-01-10"), time.Date(2024, 1, 10, 23, 59, 0, 0, tor)) {
+		t.Error("today is past just before midnight")
+	}
+	if !startsInPast(allDay("2024-01-10"), time.Date(2024, 1, 11, 0, 0, 0, 0, tor)) {
+		t.Error("yesterday isn't past at midnight")
+	}
+}
+
+func TestFetchEventStatus(t *testing.T) {
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/calendars/primary/events/live":
+			writeJSON(w, &calendar.Event{Id: "live", Status: "confirmed"})
+		case "/calendars/primary/events/cancelled":
+			writeJSON(w, &calendar.Event{Id: "cancelled", Status: "cancelled"})
+		case "/calendars/primary/events/deleted":
+			googleError(w, http.StatusGone, "deleted")
+		default:
+			googleError(w, http.StatusNotFound, "notFound")
+		}
+	})
+	tests := []struct {
+		id     string
+		status int
+	}{
+		{"live", http.StatusOK},
+		{"missing", http.StatusNotFound},
+		{"deleted", http.StatusGone},
+		{"cancelled", http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/event/"+tt.id, nil)
+			w := httptest.NewRecorder()
+			fetchEvent(w, mux.SetURLVars(r, map[string]string{"id": tt.id}))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateEventDefaultColor(t *testing.T) {
+	defer func(c string) { DefaultColor = c }(DefaultColor)
+	var colorID string
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/calendars/primary/events" {
+			colorID = decodePatch(t, r).ColorId
+			writeJSON(w, &calendar.Event{Id: "new"})
+			return
+		}
+		googleError(w, http.StatusNotFound, "notFound")
+	})
+	tests := []struct {
+		name, defaultColor, body string
+		status                   int
+		want                     string
+	}{
+		{"default applied", "Sage", `{"summary": "a", "date": "2024-01-10"}`, http.StatusCreated, "2"},
+		{"submitted kept", "Sage", `{"summary": "a", "date": "2024-01-10", "color": "Tomato"}`, http.StatusCreated, "11"},
+		{"no default", "", `{"summary": "a", "date": "2024-01-10"}`, http.StatusCreated, ""},
+		{"invalid default", "Chartreuse", `{"summary": "a", "date": "2024-01-10"}`, http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DefaultColor, colorID = tt.defaultColor, ""
+			w := httptest.NewRecorder()
+			createEvent(w, httptest.NewRequest("POST", "/event", strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if colorID != tt.want {
+				t.Errorf("colorId = %q, want %q", colorID, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateEventAttendeeMode(t *testing.T) {
+	cur := &calendar.Event{Id: "abc", Attendees: []*calendar.EventAttendee{
+		{Email: "ann@example.com", ResponseStatus: "accepted"},
+	}}
+	body := `{"attendees": [{"email": "bob@example.com"}, {"email": "ann@example.com"}]}`
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"bob@example.com needsAction", "ann@example.com needsAction"}},
+		{"?attendeeMode=replace", []string{"bob@example.com needsAction", "ann@example.com needsAction"}},
+		// Merging keeps the current attendees' responses, only adding the new ones
+		{"?attendeeMode=merge", []string{"ann@example.com accepted", "bob@example.com needsAction"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w, patched := runUpdate(t, tt.query, body, cur)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			list, _ := patched["attendees"].([]interface{})
+			var got []string
+			for _, a := range list {
+				a := a.(map[string]interface{})
+				status, _ := a["responseStatus"].(string)
+				if status == "" {
+					status = "needsAction"
+				}
+				got = append(got, a["email"].(string)+" "+status)
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("attendees = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if w, patched := runUpdate(t, "?attendeeMode=append", body, cur); w.Code != http.StatusBadRequest || patched != nil {
+		t.Errorf("invalid mode: status = %d, patched = %v", w.Code, patched)
+	}
+}
+
+func TestAssembleEventDescriptionLimit(t *testing.T) {
+	defer func(n int) { MaxDescriptionLength = n }(MaxDescriptionLength)
+	MaxDescriptionLength = 16
+
+	if _, err := assembleEvent(&newEvent{Description: strings.Repeat("x", 16)}); err != nil {
+		t.Errorf("at the limit: %v", err)
+	}
+	_, err := assembleEvent(&newEvent{Description: strings.Repeat("x", 17)})
+	if aerr, ok := err.(*apiError); !ok || aerr.Status != http.StatusBadRequest {
+		t.Errorf("over the limit: err = %v, want a 400 apiError", err)
+	}
+	// The limit is in bytes, so multi-byte characters count for more than one
+	if _, err := assembleEvent(&newEvent{Description: strings.Repeat("é", 9)}); err == nil {
+		t.Error("18 bytes of é accepted")
+	}
+}
+
+func TestToJEventUndated(t *testing.T) {
+	for _, i := range []*calendar.Event{
+		{Id: "abc", Status: "cancelled"},
+		{Id: "abc", Start: &calendar.EventDateTime{Date: "2024-01-10"}},
+	} {
+		ev := toJEvent(i, nil, time.UTC)
+		if !ev.Undated {
+			t.Errorf("%+v: not undated", i)
+		}
+		if i.Start == nil && (ev.Date != "" || !ev.start.IsZero() || ev.StartRaw != "") {
+			t.Errorf("no start: date = %q, start = %s, startRaw = %q", ev.Date, ev.start, ev.StartRaw)
+		}
+	}
+}
+
+func TestToJEventTimedStart(t *testing.T) {
+	for _, dt := range []string{"2024-01-10T15:00:00Z", "2024-01-10T10:00:00-05:00", "2024-01-11T00:00:00+09:00"} {
+		i := &calendar.Event{
+			Start: &calendar.EventDateTime{DateTime: dt},
+			End:   &calendar.EventDateTime{DateTime: dt},
+		}
+		ev := toJEvent(i, nil, time.UTC)
+		want, _ := time.Parse(time.RFC3339, dt)
+		if ev.start.IsZero() || !ev.start.Equal(want) {
+			t.Errorf("%s: start = %s", dt, ev.start)
+		}
+		if ev.Date != dt {
+			t.Errorf("%s: date = %s", dt, ev.Date)
+		}
+	}
+}
+
+func TestToJEventAllDayDST(t *testing.T) {
+	tor, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clocks went forward on 2024-03-10 and back on 2024-11-03
+	tests := []struct {
+		date, want string
+	}{
+		{"2024-03-09", "2024-03-09T00:00:00-05:00"},
+		{"2024-03-10", "2024-03-10T00:00:00-05:00"},
+		{"2024-03-11", "2024-03-11T00:00:00-04:00"},
+		{"2024-11-03", "2024-11-03T00:00:00-04:00"},
+		{"2024-11-04", "2024-11-04T00:00:00-05:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			i := &calendar.Event{
+				Start: &calendar.EventDateTime{Date: tt.date},
+				End:   &calendar.EventDateTime{Date: tt.date},
+			}
+			ev := toJEvent(i, nil, tor)
+			if !ev.AllDay || ev.Date != tt.want {
+				t.Errorf("date = %s (allDay %v), want %s", ev.Date, ev.AllDay, tt.want)
+			}
+		})
+	}
+}
+
+// runList lists the events for 2024-01-10 with query, responding as DayEvents would
+func runList(query string) *httptest.ResponseRecorder {
+	start := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	w := httptest.NewRecorder()
+	listEvents(w, httptest.NewRequest("GET", "/events/20240110"+query, nil), start, start.AddDate(0, 0, 1))
+	return w
+}
+
+func TestListPartialPages(t *testing.T) {
+	fakeEventPages(t,
+		&calendar.Events{Items: []*calendar.Event{
+			timedEvent("a", "2024-01-10T09:00:00Z"),
+			timedEvent("b", "2024-01-10T10:00:00Z"),
+		}},
+		http.StatusInternalServerError,
+	)
+
+	if w := runList(""); w.Code != http.StatusInternalServerError {
+		t.Errorf("without partial: status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	w := runList("?partial=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var env struct {
+		Truncated bool      `json:"truncated"`
+		Error     string    `json:"error"`
+		Events    []*jEvent `json:"events"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&env); err != nil {
+		t.Fatal(err)
+	}
+	if !env.Truncated || env.Error == "" {
+		t.Errorf("truncated = %v, error = %q", env.Truncated, env.Error)
+	}
+	if len(env.Events) != 2 || env.Events[0].ID != "a" || env.Events[1].ID != "b" {
+		t.Errorf("events = %+v, want the first page's a and b", env.Events)
+	}
+}
+
+func TestListPartialFirstPageFails(t *testing.T) {
+	fakeEventPages(t, http.StatusInternalServerError)
+	if w := runList("?partial=true"); w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestListPastEmptyPage(t *testing.T) {
+	fakeEventPages(t,
+		&calendar.Events{Items: []*calendar.Event{}},
+		&calendar.Events{Items: []*calendar.Event{timedEvent("a", "2024-01-10T09:00:00Z")}},
+	)
+	for _, query := range []string{"", "?stream=true"} {
+		w := runList(query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d: %s", query, w.Code, w.Body.String())
+		}
+		var res []*jEvent
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if len(res) != 1 || res[0].ID != "a" {
+			t.Errorf("%q: events = %+v, want the second page's a", query, res)
+		}
+	}
+}
+
+func TestListLocationFilter(t *testing.T) {
+	at := func(id, start, loc string) *calendar.Event {
+		ev := timedEvent(id, start)
+		ev.Location = loc
+		return ev
+	}
+	fakeEventPages(t, &calendar.Events{Items: []*calendar.Event{
+		at("a", "2024-01-10T09:00:00Z", "Board ROOM A, 2nd floor"),
+		at("b", "2024-01-10T10:00:00Z", "Room B"),
+		at("c", "2024-01-10T11:00:00Z", ""),
+		at("d", "2024-01-10T12:00:00Z", "room a"),
+	}})
+
+	w := runList("?location=room%20a")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var res []*jEvent
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ev := range res {
+		got = append(got, ev.ID)
+	}
+	if strings.Join(got, ",") != "a,d" {
+		t.Errorf("events = %v, want a,d", got)
+	}
+
+	for name, query := range map[string]string{
+		"empty":    "?location=",
+		"blank":    "?location=%20%20",
+		"too long": "?location=" + strings.Repeat("a", maxLocationFilter+1),
+	} {
+		if w := runList(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", name, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUpcomingStopsAtCount(t *testing.T) {
+	calls := 0
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/calendars/primary/events" {
+			googleError(w, http.StatusNotFound, "notFound")
+			return
+		}
+		calls++
+		writeJSON(w, &calendar.Events{
+			Items: []*calendar.Event{
+				timedEvent("a", "2030-01-10T09:00:00Z"),
+				timedEvent("b", "2030-01-10T10:00:00Z"),
+			},
+			NextPageToken: "more",
+		})
+	})
+
+	for _, query := range []string{"?count=2", "?count=2&stream=true"} {
+		calls = 0
+		w := httptest.NewRecorder()
+		UpcomingEvents(w, httptest.NewRequest("GET", "/events/upcoming"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: status = %d: %s", query, w.Code, w.Body.String())
+		}
+		var res []*jEvent
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatalf("%q: %v", query, err)
+		}
+		if len(res) != 2 {
+			t.Errorf("%q: got %d events, want 2", query, len(res))
+		}
+		if calls != 1 {
+			t.Errorf("%q: listed %d pages, want 1", query, calls)
+		}
+	}
+}
+
+func TestUpdatedKeepsUndatedCancellations(t *testing.T) {
+	defer func(s bool) { SkipUndatedEvents = s }(SkipUndatedEvents)
+	SkipUndatedEvents = true
+	fakeEventPages(t, &calendar.Events{Items: []*calendar.Event{
+		timedEvent("a", "2024-01-10T09:00:00Z"),
+		{Id: "b", Status: "cancelled"},
+		{Id: "c", Status: "confirmed"},
+	}})
+
+	w := httptest.NewRecorder()
+	UpdatedEvents(w, httptest.NewRequest("GET", "/events/updated?updatedMin=2024-01-01T00:00:00Z", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var res []*jEvent
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ev := range res {
+		got = append(got, ev.ID)
+	}
+	if strings.Join(got, ",") != "a,b" {
+		t.Errorf("events = %v, want a,b", got)
+	}
+}
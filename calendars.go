@@ -0,0 +1,207 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/calendar/v3"
+)
+
+// jCalendar is the metadata of a calendar
+type jCalendar struct {
+	ID          string `json:"id"`
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	TimeZone    string `json:"timeZone"`
+	Location    string `json:"location"`
+}
+
+func toJCalendar(c *calendar.Calendar) *jCalendar {
+	return &jCalendar{
+		ID:          c.Id,
+		Summary:     c.Summary,
+		Description: c.Description,
+		TimeZone:    c.TimeZone,
+		Location:    c.Location,
+	}
+}
+
+// GetCalendar method fetches the metadata of the calendar with id, which must
+// be allowed by calendarAllowed
+func GetCalendar(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "GetCalendar")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, missing calendar id")
+		return
+	}
+	if !calendarAllowed(id) {
+		respondErr(w, r, http.StatusForbidden, "calendar not allowed: "+id)
+		return
+	}
+
+	c, err := srv.Calendars.Get(id).Fields("id,summary,description,timeZone,location").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusNotFound, err, "Unable to retrieve calendar")
+		return
+	}
+	respond(w, r, http.StatusOK, toJCalendar(c))
+}
+
+// calendarPatch is the metadata submitted to UpdateCalendar, empty fields
+// being left as they are
+type calendarPatch struct {
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	TimeZone    string `json:"timeZone"`
+}
+
+// UpdateCalendar method patches the metadata of the calendar with id, which
+// must be allowed by calendarAllowed and owned by the user, and responds with
+// the updated metadata
+func UpdateCalendar(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UpdateCalendar")
+	defer span.End()
+
+	// Restrict method to patch only
+	if r.Method != "PATCH" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if id == "" {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, missing calendar id")
+		return
+	}
+	if !calendarAllowed(id) {
+		respondErr(w, r, http.StatusForbidden, "calendar not allowed: "+id)
+		return
+	}
+	var req calendarPatch
+	if err := decodeBody(r, &req); err != nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if req == (calendarPatch{}) {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, empty patch")
+		return
+	}
+	if req.TimeZone != "" {
+		if _, err := time.LoadLocation(req.TimeZone); err != nil {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, unknown timeZone: "+req.TimeZone)
+			return
+		}
+	}
+
+	// Only owners can change a calendar's metadata, writers just its events
+	entry, err := srv.CalendarList.Get(id).Fields("accessRole").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusNotFound, err, "Unable to retrieve calendar")
+		return
+	}
+	if entry.AccessRole != "owner" {
+		respondErr(w, r, http.StatusForbidden, "calendar metadata can only be changed by its owner")
+		return
+	}
+
+	patch := &calendar.Calendar{
+		Summary:     req.Summary,
+		Description: req.Description,
+		Location:    req.Location,
+		TimeZone:    req.TimeZone,
+	}
+	c, err := srv.Calendars.Patch(id, patch).Fields("id,summary,description,timeZone,location").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	respond(w, r, http.StatusOK, toJCalendar(c))
+}
+
+// ClearCalendar method deletes every event of the user's primary calendar,
+// which can't be undone. Google only supports clearing the primary calendar,
+// so id must be "primary". Like DeleteTaggedEvents it takes two steps, a GET
+// previewing how many events would go and a POST confirming with its token
+func ClearCalendar(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ClearCalendar")
+	defer span.End()
+
+	// Restrict method to get and post only
+	if r.Method != "GET" && r.Method != "POST" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	if id := mux.Vars(r)["id"]; id != "primary" {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, only the primary calendar can be cleared")
+		return
+	}
+	const op = "clear primary"
+	if r.Method == "GET" {
+		n := 0
+		err := srv.Events.List("primary").
+			ShowDeleted(false).
+			Fields("nextPageToken,items(id)").
+			Pages(r.Context(), func(events *calendar.Events) error {
+				n += len(events.Items)
+				return nil
+			})
+		if err != nil {
+			log.Println(err.Error())
+			respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's events")
+			return
+		}
+		respondPreview(w, r, op, n)
+		return
+	}
+	token := r.URL.Query().Get("confirm")
+	if token == "" {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, clearing permanently deletes ALL events of the primary calendar "+
+			"and can't be undone, GET a preview and pass its confirmToken as confirm to proceed")
+		return
+	}
+	if err := useConfirmToken(token, op); err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := srv.Calendars.Clear("primary").Context(r.Context()).Do(); err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PrimaryCalendar method responds with the actual id of the user's primary
+// calendar, their email address, e.g. {"id": "someone@example.com"}, for
+// clients matching the user among attendees
+func PrimaryCalendar(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "PrimaryCalendar")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	c, err := srv.Calendars.Get("primary").Fields("id").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve primary calendar")
+		return
+	}
+	respond(w, r, http.StatusOK, map[string]string{"id": c.Id})
+}
@@ -0,0 +1,174 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/calendar/v3"
+)
+
+// cloneShift moves the copy made by CloneEvent: by Offset, a Go duration
+// such as "168h", or to Date (YYYY-MM-DD) keeping the time of day
+type cloneShift struct {
+	Offset string `json:"offset"`
+	Date   string `json:"date"`
+}
+
+// CloneEvent method inserts a copy of the event with id, optionally shifted.
+// Cloning a recurring master copies the whole series, while cloning a single
+// instance creates a standalone event
+func CloneEvent(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "CloneEvent")
+	defer span.End()
+
+	// Restrict method to post only
+	if r.Method != "POST" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	vars := mux.Vars(r)
+	if vars["id"] == "" {
+		respondErr(w, r, http.StatusForbidden, "invalid request, missing event id")
+		return
+	}
+
+	var shift cloneShift
+	if r.ContentLength != 0 {
+		if err := decodeBody(r, &shift); err != nil {
+			respondErr(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	ev, err := srv.Events.Get(CalendarID, vars["id"]).Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if ev.Start == nil || ev.End == nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, event has no start or end to clone")
+		return
+	}
+	if err := shiftEvent(ev, &shift); err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	// Strip what identifies the original so Google assigns new values
+	ev.Id = ""
+	ev.ICalUID = ""
+	ev.Sequence = 0
+	ev.Etag = ""
+	ev.HtmlLink = ""
+	ev.Created = ""
+	ev.Updated = ""
+	ev.Creator = nil
+	ev.Organizer = nil
+	ev.ConferenceData = nil
+	ev.HangoutLink = ""
+	ev.RecurringEventId = ""
+	ev.OriginalStartTime = nil
+
+	cp, err := srv.Events.Insert(CalendarID, ev).Fields("id").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", eventURL(cp.Id))
+	respond(w, r, http.StatusCreated, cp)
+}
+
+// shiftEvent moves the start and end of ev as s says. Moving to a date keeps
+// the time of day in the event's time zone, even across a DST change
+func shiftEvent(ev *calendar.Event, s *cloneShift) error {
+	switch {
+	case s.Offset != "" && s.Date != "":
+		return invalidRequest("offset and date can't both be set")
+	case s.Offset != "":
+		d, err := time.ParseDuration(s.Offset)
+		if err != nil {
+			return invalidRequest("offset must be a duration such as 24h")
+		}
+		if err := shiftDateTime(ev.Start, d); err != nil {
+			return err
+		}
+		return shiftDateTime(ev.End, d)
+	case s.Date != "":
+		dt, err := time.Parse(tmLabelShort, s.Date)
+		if err != nil {
+			return invalidRequest("date must be YYYY-MM-DD")
+		}
+		st, err := eventTime(ev.Start)
+		if err != nil {
+			return err
+		}
+		day := time.Date(st.Year(), st.Month(), st.Day(), 0, 0, 0, 0, time.UTC)
+		days := int(dt.Sub(day).Hours() / 24)
+		if err := shiftDays(ev.Start, days); err != nil {
+			return err
+		}
+		return shiftDays(ev.End, days)
+	}
+	return nil
+}
+
+// eventTime parses edt, a timed one in the event's time zone when it has a
+// known one, and an all-day one as midnight UTC
+func eventTime(edt *calendar.EventDateTime) (time.Time, error) {
+	if edt.Date != "" {
+		return time.Parse(tmLabelShort, edt.Date)
+	}
+	tm, err := time.Parse(time.RFC3339, edt.DateTime)
+	if err != nil {
+		return tm, err
+	}
+	if l, err := time.LoadLocation(edt.TimeZone); err == nil && edt.TimeZone != "" {
+		tm = tm.In(l)
+	}
+	return tm, nil
+}
+
+// shiftDays moves edt by days calendar days, keeping its time of day
+func shiftDays(edt *calendar.EventDateTime, days int) error {
+	if days == 0 {
+		return nil
+	}
+	tm, err := eventTime(edt)
+	if err != nil {
+		return err
+	}
+	tm = tm.AddDate(0, 0, days)
+	if edt.Date != "" {
+		edt.Date = tm.Format(tmLabelShort)
+		return nil
+	}
+	edt.DateTime = tm.Format(time.RFC3339)
+	return nil
+}
+
+// shiftDateTime moves edt by delta, which must be whole days for an all-day date
+func shiftDateTime(edt *calendar.EventDateTime, delta time.Duration) error {
+	if delta == 0 {
+		return nil
+	}
+	if edt.Date != "" && delta%(24*time.Hour) != 0 {
+		return invalidRequest("all-day events can only shift by whole days")
+	}
+	tm, err := eventTime(edt)
+	if err != nil {
+		return err
+	}
+	// eventTime puts the time in the event's zone, so the offset is right
+	// if the shift crosses a DST change there
+	tm = tm.Add(delta)
+	if edt.Date != "" {
+		edt.Date = tm.Format(tmLabelShort)
+		return nil
+	}
+	edt.DateTime = tm.Format(time.RFC3339)
+	return nil
+}
@@ -0,0 +1,88 @@
+package calendar
+
+import (
+	"testing"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestShiftEventDate(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end calendar.EventDateTime
+		date       string
+		wantStart  string
+		wantEnd    string
+	}{
+		{
+			name:      "into daylight time",
+			start:     calendar.EventDateTime{DateTime: "2024-01-10T09:00:00-05:00", TimeZone: "America/New_York"},
+			end:       calendar.EventDateTime{DateTime: "2024-01-10T10:30:00-05:00", TimeZone: "America/New_York"},
+			date:      "2024-07-10",
+			wantStart: "2024-07-10T09:00:00-04:00",
+			wantEnd:   "2024-07-10T10:30:00-04:00",
+		},
+		{
+			name:      "out of daylight time",
+			start:     calendar.EventDateTime{DateTime: "2024-07-10T09:00:00-04:00", TimeZone: "America/New_York"},
+			end:       calendar.EventDateTime{DateTime: "2024-07-10T10:00:00-04:00", TimeZone: "America/New_York"},
+			date:      "2024-12-02",
+			wantStart: "2024-12-02T09:00:00-05:00",
+			wantEnd:   "2024-12-02T10:00:00-05:00",
+		},
+		{
+			name:      "given in another zone",
+			start:     calendar.EventDateTime{DateTime: "2024-01-10T14:00:00Z", TimeZone: "America/New_York"},
+			end:       calendar.EventDateTime{DateTime: "2024-01-10T15:00:00Z", TimeZone: "America/New_York"},
+			date:      "2024-07-10",
+			wantStart: "2024-07-10T09:00:00-04:00",
+			wantEnd:   "2024-07-10T10:00:00-04:00",
+		},
+		{
+			name:      "all-day",
+			start:     calendar.EventDateTime{Date: "2024-02-28"},
+			end:       calendar.EventDateTime{Date: "2024-03-01"},
+			date:      "2024-03-10",
+			wantStart: "2024-03-10",
+			wantEnd:   "2024-03-12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := &calendar.Event{Start: &tt.start, End: &tt.end}
+			if err := shiftEvent(ev, &cloneShift{Date: tt.date}); err != nil {
+				t.Fatal(err)
+			}
+			if got := ev.Start.DateTime + ev.Start.Date; got != tt.wantStart {
+				t.Errorf("start = %s, want %s", got, tt.wantStart)
+			}
+			if got := ev.End.DateTime + ev.End.Date; got != tt.wantEnd {
+				t.Errorf("end = %s, want %s", got, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestShiftEventInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		shift cloneShift
+	}{
+		{"offset and date", "2024-01-10", cloneShift{Offset: "24h", Date: "2024-01-11"}},
+		{"bad offset", "2024-01-10", cloneShift{Offset: "tomorrow"}},
+		{"bad date", "2024-01-10", cloneShift{Date: "10/01/2024"}},
+		{"partial day on all-day", "2024-01-10", cloneShift{Offset: "36h"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := &calendar.Event{
+				Start: &calendar.EventDateTime{Date: tt.start},
+				End:   &calendar.EventDateTime{Date: tt.start},
+			}
+			if err := shiftEvent(ev, &tt.shift); err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+}
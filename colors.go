@@ -0,0 +1,136 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/calendar/v3"
+)
+
+// eventColorNames holds the names Google Calendar shows for each event color, indexed by color id
+var eventColorNames = []string{"", "Lavender", "Sage", "Grape", "Flamingo", "Banana",
+	"Tangerine", "Peacock", "Graphite", "Blueberry", "Basil", "Tomato"}
+
+// colorID resolves a color name (case insensitive) or raw numeric id to Google's color id
+func colorID(c string) (string, error) {
+	for id, name := range eventColorNames[1:] {
+		id := strconv.Itoa(id + 1)
+		if c == id || strings.EqualFold(c, name) {
+			return id, nil
+		}
+	}
+	return "", invalidRequest("invalid color: " + c + ", valid names are " +
+		strings.Join(eventColorNames[1:], ", "))
+}
+
+// colorCache holds the background of each event color id, fetched from Google
+// and refetched once older than ColorCacheTTL. It's safe for concurrent use
+type colorCache struct {
+	mu      sync.RWMutex
+	bgd     map[string]string
+	fetched time.Time
+}
+
+// eventColors is the event color palette shared by all requests
+var eventColors colorCache
+
+// Background returns the background for color id, and false when the id is
+// unknown or the palette couldn't be fetched
+func (c *colorCache) Background(id string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	bg, ok := c.bgd[id]
+	return bg, ok
+}
+
+// refresh fetches the palette when missing or stale. On error a stale palette is kept
+func (c *colorCache) refresh(ctx context.Context) error {
+	c.mu.RLock()
+	fresh := c.bgd != nil && time.Since(c.fetched) < ColorCacheTTL
+	c.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	clrs, err := srv.Colors.Get().Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	bgd := make(map[string]string, len(clrs.Event))
+	for id, def := range clrs.Event {
+		bgd[id] = def.Background
+	}
+	c.mu.Lock()
+	c.bgd = bgd
+	c.fetched = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// uncoloredKey buckets the events ColorCounts finds without a color of their
+// own, which show in the calendar's color
+const uncoloredKey = "default"
+
+// colorCounts are event counts keyed by color name
+type colorCounts map[string]int
+
+func (colorCounts) dataKeyed() {}
+
+// ColorCounts method counts the events between the required start and end
+// query params by color, keyed by the color's name, e.g. {"Tomato": 4}.
+// Events without a color are counted under "default", and ids with no known
+// name under the id itself. The names are kept as they are with ?format=snake
+func ColorCounts(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ColorCounts")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	start, end, err := timeRange(r, true)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	res := colorCounts{}
+	err = srv.Events.List(CalendarID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(start.Format(time.RFC3339)).
+		TimeMax(end.Format(time.RFC3339)).
+		Fields("nextPageToken,items(colorId)").
+		Pages(r.Context(), func(events *calendar.Events) error {
+			for _, i := range events.Items {
+				res[colorName(i.ColorId)]++
+			}
+			return nil
+		})
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's events")
+		return
+	}
+	respond(w, r, http.StatusOK, res)
+}
+
+// colorName returns the name of color id, uncoloredKey when id is empty
+func colorName(id string) string {
+	if id == "" {
+		return uncoloredKey
+	}
+	if n, err := strconv.Atoi(id); err == nil && n > 0 && n < len(eventColorNames) {
+		return eventColorNames[n]
+	}
+	return id
+}
@@ -0,0 +1,74 @@
+package calendar
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// confirmPreview is the response to previewing a destructive operation: how
+// many events it would delete, and the token to pass as confirm to go ahead
+type confirmPreview struct {
+	Count   int    `json:"count"`
+	Token   string `json:"confirmToken"`
+	Expires string `json:"expires"`
+}
+
+// confirmation is an issued token, valid for the operation op until expires
+type confirmation struct {
+	op      string
+	expires time.Time
+}
+
+var (
+	confirmMu     sync.Mutex
+	confirmations = map[string]confirmation{}
+)
+
+// issueConfirmToken returns a token confirming op, which describes the
+// operation and its parameters so the token can't confirm another. The token
+// expires after ConfirmTokenTTL
+func issueConfirmToken(op string, count int) (*confirmPreview, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+	expires := now.Add(ConfirmTokenTTL)
+
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	for t, c := range confirmations {
+		if now.After(c.expires) {
+			delete(confirmations, t)
+		}
+	}
+	confirmations[token] = confirmation{op: op, expires: expires}
+	return &confirmPreview{Count: count, Token: token, Expires: expires.Format(time.RFC3339)}, nil
+}
+
+// useConfirmToken checks token was issued for op and hasn't expired. A token
+// can only be used once
+func useConfirmToken(token, op string) error {
+	confirmMu.Lock()
+	defer confirmMu.Unlock()
+	c, ok := confirmations[token]
+	delete(confirmations, token)
+	if !ok || c.op != op || time.Now().After(c.expires) {
+		return &apiError{Status: http.StatusConflict, Msg: "confirm token is invalid or expired, preview again for a new one"}
+	}
+	return nil
+}
+
+// respondPreview responds with a confirmPreview of op affecting count events
+func respondPreview(w http.ResponseWriter, r *http.Request, op string, count int) {
+	p, err := issueConfirmToken(op, count)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	respond(w, r, http.StatusOK, p)
+}
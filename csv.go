@@ -0,0 +1,116 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// ExportCSV method writes the events between the required start and end query
+// params as CSV for spreadsheets, a row per event of date, start, end,
+// summary, location and attendees. Times are in the tz location. All-day
+// events leave start empty, with end only set to the last day when they span
+// several, and timed events give the end's date too when it's another day
+func ExportCSV(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ExportCSV")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	start, end, err := timeRange(r, true)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	l, err := tzParam(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var b bytes.Buffer
+	cw := csv.NewWriter(&b)
+	cw.Write([]string{"date", "start", "end", "summary", "location", "attendees"})
+	err = srv.Events.List(CalendarID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(start.Format(time.RFC3339)).
+		TimeMax(end.Format(time.RFC3339)).
+		OrderBy("startTime").
+		Fields("nextPageToken,items(start,end,summary,location,attendees(email))").
+		Pages(r.Context(), func(events *calendar.Events) error {
+			for _, i := range events.Items {
+				if i.Start == nil || i.End == nil {
+					continue
+				}
+				cw.Write(csvRow(i, l))
+			}
+			return nil
+		})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's events")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="calendar.csv"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.Bytes())
+}
+
+// csvRow returns the CSV columns of event i, its times in location l
+func csvRow(i *calendar.Event, l *time.Location) []string {
+	var emails []string
+	for _, a := range i.Attendees {
+		emails = append(emails, a.Email)
+	}
+	row := []string{"", "", "", csvText(i.Summary), csvText(i.Location), csvText(strings.Join(emails, "; "))}
+
+	if i.Start.Date != "" {
+		row[0] = i.Start.Date
+		// Google's end date is exclusive
+		st, err1 := time.Parse(tmLabelShort, i.Start.Date)
+		en, err2 := time.Parse(tmLabelShort, i.End.Date)
+		if err1 == nil && err2 == nil {
+			if last := en.AddDate(0, 0, -1); last.After(st) {
+				row[2] = last.Format(tmLabelShort)
+			}
+		}
+		return row
+	}
+	st, err1 := time.Parse(time.RFC3339, i.Start.DateTime)
+	en, err2 := time.Parse(time.RFC3339, i.End.DateTime)
+	if err1 != nil || err2 != nil {
+		return row
+	}
+	st, en = st.In(l), en.In(l)
+	row[0] = st.Format(tmLabelShort)
+	row[1] = st.Format("15:04")
+	row[2] = en.Format("15:04")
+	if en.Format(tmLabelShort) != row[0] {
+		row[2] = en.Format(tmLabelShort + " 15:04")
+	}
+	return row
+}
+
+// csvText quotes s with a leading ' when spreadsheets would read it as a
+// formula, since summaries and locations come from whoever sent the invite
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,38 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestCSVRowFormulas(t *testing.T) {
+	tests := []struct {
+		summary, want string
+	}{
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1 555 0100", "'+1 555 0100"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1:A2)", "'@SUM(A1:A2)"},
+		{"Planning", "Planning"},
+		{"a = b", "a = b"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.summary, func(t *testing.T) {
+			row := csvRow(&calendar.Event{
+				Summary:   tt.summary,
+				Location:  tt.summary,
+				Attendees: []*calendar.EventAttendee{{Email: tt.summary}},
+				Start:     &calendar.EventDateTime{Date: "2024-01-10"},
+				End:       &calendar.EventDateTime{Date: "2024-01-11"},
+			}, time.UTC)
+			for _, col := range []int{3, 4, 5} {
+				if row[col] != tt.want {
+					t.Errorf("column %d = %q, want %q", col, row[col], tt.want)
+				}
+			}
+		})
+	}
+}
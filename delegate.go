@@ -0,0 +1,65 @@
+package calendar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/calendar/v3"
+)
+
+// subjectHeader names the user a request acts as under domain-wide delegation
+const subjectHeader = "X-Calendar-Subject"
+
+var (
+	delegatedMu  sync.Mutex
+	delegatedSrv = map[string]*calendar.Service{}
+)
+
+// serviceFor returns the service a request should use: srv, or with the
+// subject header set, one acting as that user through DelegationKeyFile. The
+// subject must be an address in DelegationDomains, and the service account
+// must have been granted the calendar scope in the Workspace admin console
+func serviceFor(r *http.Request) (*calendar.Service, error) {
+	v := r.Header.Get(subjectHeader)
+	if v == "" {
+		return srv, nil
+	}
+	if DelegationKeyFile == "" {
+		return nil, &apiError{Status: http.StatusForbidden, Msg: "delegation isn't enabled"}
+	}
+	subject, err := normalizeEmail(v)
+	if err != nil {
+		return nil, invalidRequest("invalid " + subjectHeader + ": " + v)
+	}
+	domain := strings.ToLower(subject[strings.LastIndex(subject, "@")+1:])
+	if !contains(DelegationDomains, domain) {
+		return nil, &apiError{Status: http.StatusForbidden, Msg: "delegation isn't allowed for " + subject}
+	}
+
+	delegatedMu.Lock()
+	defer delegatedMu.Unlock()
+	if s, ok := delegatedSrv[subject]; ok {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile(DelegationKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := google.JWTConfigFromJSON(b, calendar.CalendarScope)
+	if err != nil {
+		return nil, err
+	}
+	conf.Subject = subject
+	client := conf.Client(context.Background())
+	client.Transport = &tracingTransport{base: &breakerTransport{base: client.Transport}}
+	s, err := calendar.New(client)
+	if err != nil {
+		return nil, err
+	}
+	delegatedSrv[subject] = s
+	return s, nil
+}
@@ -0,0 +1,85 @@
+package calendar
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+)
+
+// rateLimitReasons are the googleapi.Error reasons Google gives, with a 403,
+// for exceeded quotas and rate limits rather than missing permissions
+var rateLimitReasons = []string{"rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded", "dailyLimitExceeded"}
+
+// defaultRetryAfter is the Retry-After, in seconds, sent when Google gives none
+const defaultRetryAfter = "30"
+
+// rateLimited reports whether gerr is Google refusing for quota or rate limits
+func rateLimited(gerr *googleapi.Error) bool {
+	if gerr.Code == http.StatusTooManyRequests {
+		return true
+	}
+	if gerr.Code != http.StatusForbidden {
+		return false
+	}
+	for _, e := range gerr.Errors {
+		if contains(rateLimitReasons, e.Reason) {
+			return true
+		}
+	}
+	return false
+}
+
+// apiError is an error with the HTTP status it should be responded with,
+// returned by the validating helpers so handlers needn't pick statuses
+type apiError struct {
+	Status int
+	Msg    string
+}
+
+func (e *apiError) Error() string {
+	return e.Msg
+}
+
+// invalidRequest returns the 400 apiError for a request failing validation
+func invalidRequest(msg string) error {
+	return &apiError{Status: http.StatusBadRequest, Msg: "invalid request, " + msg}
+}
+
+// respondError responds to err: an apiError with its own status and message,
+// a failed Google API call with the status Google gave, and anything else with
+// status. args is the message when set, err otherwise. Quota and rate limit
+// 403s from Google become 429 with a Retry-After, leaving 403 for genuine
+// permission errors
+func respondError(w http.ResponseWriter, r *http.Request,
+	status int, err error, args ...interface{},
+) {
+	var aerr *apiError
+	if errors.As(err, &aerr) {
+		respondErr(w, r, aerr.Status, aerr.Msg)
+		return
+	}
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		status = gerr.Code
+		if rateLimited(gerr) {
+			status = http.StatusTooManyRequests
+			ra := gerr.Header.Get("Retry-After")
+			if ra == "" {
+				ra = defaultRetryAfter
+			}
+			w.Header().Set("Retry-After", ra)
+		} else if ra := gerr.Header.Get("Retry-After"); ra != "" && status == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", ra)
+		}
+	}
+	// The Google client wraps the request's context error in a *url.Error
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	if len(args) == 0 {
+		args = []interface{}{err.Error()}
+	}
+	respondErr(w, r, status, args...)
+}
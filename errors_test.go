@@ -0,0 +1,108 @@
+package calendar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
+)
+
+func TestValidatorStatus(t *testing.T) {
+	lat, lng := 91.0, 0.0
+	tests := []struct {
+		name string
+		err  func() error
+	}{
+		{"unknown color", func() error { _, err := colorID("Chartreuse"); return err }},
+		{"empty iCalUID", func() error { return validICalUID("") }},
+		{"iCalUID with space", func() error { return validICalUID("a b@example.com") }},
+		{"latitude", func() error { return setGeo(&calendar.Event{}, &lat, &lng) }},
+		{"month", func() error { _, _, err := monthRange("2024", time.UTC); return err }},
+		{"range keyword", func() error { _, _, err := relativeRange("someday", time.Now()); return err }},
+		{"attendee", func() error { _, err := assembleAttendees([]newAttendee{{Email: "nobody"}}); return err }},
+		{"time range", func() error {
+			r := httptest.NewRequest("GET", "/events?start=yesterday", nil)
+			_, _, err := timeRange(r, false)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err()
+			if err == nil {
+				t.Fatal("want an error")
+			}
+			// The fallback status must not be the one used
+			w := httptest.NewRecorder()
+			respondError(w, httptest.NewRequest("GET", "/", nil), http.StatusInternalServerError, err)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if !strings.Contains(w.Body.String(), "invalid request") {
+				t.Errorf("body = %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestColorIDValid(t *testing.T) {
+	for _, c := range []string{"tomato", "Tomato", "11"} {
+		id, err := colorID(c)
+		if err != nil || id != "11" {
+			t.Errorf("colorID(%q) = %q, %v, want 11", c, id, err)
+		}
+	}
+}
+
+func TestRespondErrorRateLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        *googleapi.Error
+		status     int
+		retryAfter string
+	}{
+		{"rate limit", forbidden("rateLimitExceeded"), http.StatusTooManyRequests, defaultRetryAfter},
+		{"user rate limit", forbidden("userRateLimitExceeded"), http.StatusTooManyRequests, defaultRetryAfter},
+		{"quota", forbidden("quotaExceeded"), http.StatusTooManyRequests, defaultRetryAfter},
+		{"permission", forbidden("forbidden"), http.StatusForbidden, ""},
+		{"no reason", &googleapi.Error{Code: http.StatusForbidden}, http.StatusForbidden, ""},
+		{
+			"retry after given",
+			&googleapi.Error{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"5"}}},
+			http.StatusTooManyRequests, "5",
+		},
+		{
+			"unavailable",
+			&googleapi.Error{Code: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"120"}}},
+			http.StatusServiceUnavailable, "120",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			respondError(w, httptest.NewRequest("GET", "/", nil), http.StatusInternalServerError, tt.err)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	if !rateLimited(forbidden("dailyLimitExceeded")) {
+		t.Error("dailyLimitExceeded 403 isn't rate limited")
+	}
+	if rateLimited(forbidden("insufficientPermissions")) {
+		t.Error("insufficientPermissions 403 is rate limited")
+	}
+	if rateLimited(&googleapi.Error{Code: http.StatusNotFound, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}) {
+		t.Error("404 is rate limited")
+	}
+}
@@ -0,0 +1,137 @@
+package calendar
+
+import (
+	"strings"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// autoDeclineModes are the ways Google can decline invitations during
+// out-of-office and focus-time events
+var autoDeclineModes = []string{"declineNone", "declineAllConflictingInvitations",
+	"declineOnlyNewConflictingInvitations"}
+
+// workingLocationTypes are the kinds of place a working location event gives
+var workingLocationTypes = []string{"homeOffice", "officeLocation", "customLocation"}
+
+// workingLocation is where the user works during a workingLocation event.
+// Type is one of workingLocationTypes, an officeLocation needing a Label or
+// BuildingID and a customLocation a Label
+type workingLocation struct {
+	Type       string `json:"type"`
+	Label      string `json:"label,omitempty"`
+	BuildingID string `json:"buildingId,omitempty"`
+	FloorID    string `json:"floorId,omitempty"`
+	DeskID     string `json:"deskId,omitempty"`
+}
+
+// setEventType validates the event type of s and sets it on evt along with
+// the properties Google requires for it. Out-of-office and focus-time events
+// must be timed and need an autoDeclineMode, working location events need a
+// workingLocation
+func setEventType(evt *calendar.Event, s *newEvent) error {
+	if s.WorkingLocation != nil && s.EventType != "workingLocation" {
+		return invalidRequest("workingLocation needs a workingLocation eventType")
+	}
+	switch s.EventType {
+	case "", "default":
+		if s.AutoDeclineMode != "" || s.DeclineMessage != "" || s.ChatStatus != "" {
+			return invalidRequest("autoDeclineMode, declineMessage and chatStatus need an outOfOffice or focusTime eventType")
+		}
+		evt.EventType = s.EventType
+		return nil
+	case "workingLocation":
+		return setWorkingLocation(evt, s)
+	case "outOfOffice", "focusTime":
+	default:
+		return invalidRequest("eventType must be default, outOfOffice, focusTime or workingLocation")
+	}
+
+	if evt.Start != nil && evt.Start.Date != "" {
+		return invalidRequest(s.EventType + " events can't be all-day, set start and end")
+	}
+	if !contains(autoDeclineModes, s.AutoDeclineMode) {
+		return invalidRequest(s.EventType + " events need an autoDeclineMode of declineNone, " +
+			"declineAllConflictingInvitations or declineOnlyNewConflictingInvitations")
+	}
+	evt.EventType = s.EventType
+	if s.EventType == "outOfOffice" {
+		if s.ChatStatus != "" {
+			return invalidRequest("chatStatus is only for focusTime events")
+		}
+		evt.OutOfOfficeProperties = &calendar.EventOutOfOfficeProperties{
+			AutoDeclineMode: s.AutoDeclineMode,
+			DeclineMessage:  s.DeclineMessage,
+		}
+		return nil
+	}
+	if s.ChatStatus != "" && s.ChatStatus != "available" && s.ChatStatus != "doNotDisturb" {
+		return invalidRequest("chatStatus must be available or doNotDisturb")
+	}
+	evt.FocusTimeProperties = &calendar.EventFocusTimeProperties{
+		AutoDeclineMode: s.AutoDeclineMode,
+		DeclineMessage:  s.DeclineMessage,
+		ChatStatus:      s.ChatStatus,
+	}
+	return nil
+}
+
+// setWorkingLocation sets the working location of s on evt. Google only
+// accepts working location events that are public and don't block time
+func setWorkingLocation(evt *calendar.Event, s *newEvent) error {
+	if s.AutoDeclineMode != "" || s.DeclineMessage != "" || s.ChatStatus != "" {
+		return invalidRequest("autoDeclineMode, declineMessage and chatStatus need an outOfOffice or focusTime eventType")
+	}
+	wl := s.WorkingLocation
+	if wl == nil {
+		return invalidRequest("workingLocation events need a workingLocation")
+	}
+	if evt.Transparency == "opaque" || (evt.Visibility != "" && evt.Visibility != "public") {
+		return invalidRequest("workingLocation events must be transparent and public")
+	}
+	props := &calendar.EventWorkingLocationProperties{Type: wl.Type}
+	switch wl.Type {
+	case "homeOffice":
+		props.HomeOffice = map[string]interface{}{}
+	case "officeLocation":
+		if wl.Label == "" && wl.BuildingID == "" {
+			return invalidRequest("officeLocation needs a label or buildingId")
+		}
+		props.OfficeLocation = &calendar.EventWorkingLocationPropertiesOfficeLocation{
+			Label:      wl.Label,
+			BuildingId: wl.BuildingID,
+			FloorId:    wl.FloorID,
+			DeskId:     wl.DeskID,
+		}
+	case "customLocation":
+		if wl.Label == "" {
+			return invalidRequest("customLocation needs a label")
+		}
+		props.CustomLocation = &calendar.EventWorkingLocationPropertiesCustomLocation{Label: wl.Label}
+	default:
+		return invalidRequest("workingLocation type must be among " + strings.Join(workingLocationTypes, ", "))
+	}
+	evt.EventType = s.EventType
+	evt.Transparency = "transparent"
+	evt.Visibility = "public"
+	evt.WorkingLocationProperties = props
+	return nil
+}
+
+// toWorkingLocation converts the working location of a listed event
+func toWorkingLocation(p *calendar.EventWorkingLocationProperties) *workingLocation {
+	if p == nil {
+		return nil
+	}
+	wl := &workingLocation{Type: p.Type}
+	switch {
+	case p.OfficeLocation != nil:
+		wl.Label = p.OfficeLocation.Label
+		wl.BuildingID = p.OfficeLocation.BuildingId
+		wl.FloorID = p.OfficeLocation.FloorId
+		wl.DeskID = p.OfficeLocation.DeskId
+	case p.CustomLocation != nil:
+		wl.Label = p.CustomLocation.Label
+	}
+	return wl
+}
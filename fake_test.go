@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// fakeGoogle points srv at a test server answering the Calendar API calls
+// with h, for the duration of the test
+func fakeGoogle(t *testing.T, h http.HandlerFunc) {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	s, err := calendar.New(ts.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.BasePath = ts.URL + "/"
+	old := srv
+	srv = s
+	t.Cleanup(func() {
+		srv = old
+		ts.Close()
+	})
+}
+
+// writeJSON answers a fake API call with v
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// googleError answers a fake API call with the error body Google sends
+func googleError(w http.ResponseWriter, code int, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": reason,
+			"errors":  []map[string]string{{"reason": reason, "message": reason}},
+		},
+	})
+}
+
+// decodePatch decodes the event sent by a fake API call
+func decodePatch(t *testing.T, r *http.Request) *calendar.Event {
+	t.Helper()
+	var ev calendar.Event
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+		t.Errorf("decoding %s %s: %v", r.Method, r.URL.Path, err)
+	}
+	return &ev
+}
+
+// fakeEventPages serves the calendar's event list as pages, each either the
+// *calendar.Events to list or the int status to fail with. Pages after the
+// first are asked for by the page token "p<index>"
+func fakeEventPages(t *testing.T, pages ...interface{}) {
+	t.Helper()
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/calendars/primary/events" {
+			googleError(w, http.StatusNotFound, "notFound")
+			return
+		}
+		idx := 0
+		if tok := r.URL.Query().Get("pageToken"); tok != "" {
+			fmt.Sscanf(tok, "p%d", &idx)
+		}
+		switch p := pages[idx].(type) {
+		case int:
+			googleError(w, p, "backendError")
+		case *calendar.Events:
+			page := *p
+			if idx+1 < len(pages) {
+				page.NextPageToken = fmt.Sprintf("p%d", idx+1)
+			}
+			writeJSON(w, &page)
+		}
+	})
+}
+
+// timedEvent returns an hour long event with id starting at start
+func timedEvent(id, start string) *calendar.Event {
+	st, _ := time.Parse(time.RFC3339, start)
+	return &calendar.Event{
+		Id:     id,
+		Status: "confirmed",
+		Start:  &calendar.EventDateTime{DateTime: start},
+		End:    &calendar.EventDateTime{DateTime: st.Add(time.Hour).Format(time.RFC3339)},
+	}
+}
@@ -0,0 +1,59 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode"
+)
+
+// dataKeyed is implemented by responses whose object keys are data, such as
+// color names, rather than field names, which ?format=snake leaves alone
+type dataKeyed interface {
+	dataKeyed()
+}
+
+// toSnakeJSON re-encodes a JSON document with every object key converted from
+// camelCase to snake_case, e.g. allDayEvent to all_day_event
+func toSnakeJSON(b []byte) ([]byte, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(snakeKeys(v))
+}
+
+func snakeKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[snakeCase(k)] = snakeKeys(val)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = snakeKeys(t[i])
+		}
+	}
+	return v
+}
+
+// snakeCase converts a camelCase key, keeping runs of capitals together (iCalUID to i_cal_uid)
+func snakeCase(s string) string {
+	rs := []rune(s)
+	var b bytes.Buffer
+	for i, c := range rs {
+		if unicode.IsUpper(c) {
+			prevLower := i > 0 && !unicode.IsUpper(rs[i-1])
+			endOfRun := i > 0 && i+1 < len(rs) && unicode.IsUpper(rs[i-1]) && unicode.IsLower(rs[i+1])
+			if prevLower || endOfRun {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,195 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+const defaultSlotDuration = 30 * time.Minute
+
+type freeSlot struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// period is a busy or free interval
+type period struct {
+	start, end time.Time
+}
+
+// FreeSlots method returns the open slots between start and end, those within
+// working hours and free on each calendar in the calendars query param (the
+// handlers' calendar by default). Slots are at least duration long (30m by
+// default), and working hours are WorkdayStart to WorkdayEnd on WorkingDays
+// unless overridden with dayStart and dayEnd (HH:MM)
+func FreeSlots(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "FreeSlots")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	ids := []string{CalendarID}
+	if r.URL.Query().Get("calendars") != "" {
+		var err error
+		if ids, err = calendarIDs(r); err != nil {
+			respondError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+	start, end, err := timeRange(r, true)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	l, err := tzParam(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	dayStart, dayEnd, err := workingHours(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	min := defaultSlotDuration
+	if v := r.URL.Query().Get("duration"); v != "" {
+		min, err = time.ParseDuration(v)
+		if err != nil || min <= 0 {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, duration must be a positive duration such as 30m")
+			return
+		}
+	}
+	if min > dayEnd-dayStart {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, duration is longer than the working day")
+		return
+	}
+
+	req := &calendar.FreeBusyRequest{
+		TimeMin: start.Format(time.RFC3339),
+		TimeMax: end.Format(time.RFC3339),
+	}
+	for _, id := range ids {
+		req.Items = append(req.Items, &calendar.FreeBusyRequestItem{Id: id})
+	}
+	fb, err := srv.Freebusy.Query(req).Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve free/busy")
+		return
+	}
+	var busy []period
+	for id, c := range fb.Calendars {
+		if len(c.Errors) > 0 {
+			respondErr(w, r, http.StatusInternalServerError, "Unable to retrieve free/busy for "+id+": "+c.Errors[0].Reason)
+			return
+		}
+		for _, b := range c.Busy {
+			bs, err1 := time.Parse(time.RFC3339, b.Start)
+			be, err2 := time.Parse(time.RFC3339, b.End)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			busy = append(busy, period{bs, be})
+		}
+	}
+
+	res := []freeSlot{}
+	for _, p := range freePeriods(workingPeriods(start, end, l, dayStart, dayEnd), busy, min) {
+		res = append(res, freeSlot{
+			Start: p.start.In(l).Format(time.RFC3339),
+			End:   p.end.In(l).Format(time.RFC3339),
+		})
+	}
+	respond(w, r, http.StatusOK, res)
+}
+
+// workingHours parses the dayStart and dayEnd query params, defaulting to
+// WorkdayStart and WorkdayEnd, as offsets from midnight
+func workingHours(r *http.Request) (time.Duration, time.Duration, error) {
+	var res [2]time.Duration
+	for idx, name := range []string{"dayStart", "dayEnd"} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			v = []string{WorkdayStart, WorkdayEnd}[idx]
+		}
+		tm, err := time.Parse("15:04", v)
+		if err != nil {
+			return 0, 0, invalidRequest(name + " must be HH:MM")
+		}
+		res[idx] = time.Duration(tm.Hour())*time.Hour + time.Duration(tm.Minute())*time.Minute
+	}
+	if res[1] <= res[0] {
+		return 0, 0, invalidRequest("dayEnd must be after dayStart")
+	}
+	return res[0], res[1], nil
+}
+
+// workingPeriods returns the working hours of each working day between start
+// and end in location l, clipped to the range
+func workingPeriods(start, end time.Time, l *time.Location, dayStart, dayEnd time.Duration) []period {
+	var res []period
+	s := start.In(l)
+	for day := time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, l); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !containsWeekday(WorkingDays, day.Weekday()) {
+			continue
+		}
+		// Built from the clock time rather than added to midnight, so DST
+		// changes don't shift the working hours
+		from := time.Date(day.Year(), day.Month(), day.Day(), 0, int(dayStart/time.Minute), 0, 0, l)
+		to := time.Date(day.Year(), day.Month(), day.Day(), 0, int(dayEnd/time.Minute), 0, 0, l)
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if to.After(from) {
+			res = append(res, period{from, to})
+		}
+	}
+	return res
+}
+
+// freePeriods subtracts the busy periods from each working period, keeping
+// the gaps at least min long
+func freePeriods(working, busy []period, min time.Duration) []period {
+	sort.Slice(busy, func(a, b int) bool { return busy[a].start.Before(busy[b].start) })
+	var res []period
+	for _, wp := range working {
+		from := wp.start
+		for _, b := range busy {
+			if !b.end.After(from) {
+				continue
+			}
+			if !b.start.Before(wp.end) {
+				break
+			}
+			if b.start.Sub(from) >= min {
+				res = append(res, period{from, b.start})
+			}
+			if b.end.After(from) {
+				from = b.end
+			}
+		}
+		if wp.end.Sub(from) >= min {
+			res = append(res, period{from, wp.end})
+		}
+	}
+	return res
+}
+
+func containsWeekday(list []time.Weekday, d time.Weekday) bool {
+	for _, v := range list {
+		if v == d {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package calendar
+
+import (
+	"strconv"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Private extended property keys used to store event coordinates
+const (
+	propLatitude  = "latitude"
+	propLongitude = "longitude"
+)
+
+// setGeo validates the coordinates and stores them as private extended properties
+func setGeo(evt *calendar.Event, lat, lng *float64) error {
+	if lat == nil && lng == nil {
+		return nil
+	}
+	if lat == nil || lng == nil {
+		return invalidRequest("latitude and longitude must be set together")
+	}
+	if *lat < -90 || *lat > 90 {
+		return invalidRequest("latitude must be between -90 and 90")
+	}
+	if *lng < -180 || *lng > 180 {
+		return invalidRequest("longitude must be between -180 and 180")
+	}
+	if evt.ExtendedProperties == nil {
+		evt.ExtendedProperties = &calendar.EventExtendedProperties{}
+	}
+	if evt.ExtendedProperties.Private == nil {
+		evt.ExtendedProperties.Private = map[string]string{}
+	}
+	evt.ExtendedProperties.Private[propLatitude] = strconv.FormatFloat(*lat, 'f', -1, 64)
+	evt.ExtendedProperties.Private[propLongitude] = strconv.FormatFloat(*lng, 'f', -1, 64)
+	return nil
+}
+
+// getGeo reads the coordinates back from the private extended properties
+func getGeo(i *calendar.Event) (lat, lng *float64) {
+	if i.ExtendedProperties == nil || i.ExtendedProperties.Private == nil {
+		return nil, nil
+	}
+	la, err := strconv.ParseFloat(i.ExtendedProperties.Private[propLatitude], 64)
+	if err != nil {
+		return nil, nil
+	}
+	ln, err := strconv.ParseFloat(i.ExtendedProperties.Private[propLongitude], 64)
+	if err != nil {
+		return nil, nil
+	}
+	return &la, &ln
+}
@@ -0,0 +1,461 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+const (
+	tmICSDate     = "20060102"
+	tmICSDateTime = "20060102T150405"
+	tmICSUTC      = "20060102T150405Z"
+)
+
+// icsProp is a single unfolded content line, e.g. DTSTART;TZID=America/Toronto:20150721T090000
+type icsProp struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icsEvent holds the properties of one VEVENT block
+type icsEvent struct {
+	Props []icsProp
+	Err   error
+}
+
+// get returns the first property with name, or nil
+func (e *icsEvent) get(name string) *icsProp {
+	for i := range e.Props {
+		if e.Props[i].Name == name {
+			return &e.Props[i]
+		}
+	}
+	return nil
+}
+
+// ImportICS method imports each VEVENT of an uploaded RFC 5545 file, either as
+// a multipart "file" field or the raw request body. Events with a UID are
+// imported with Events.Import so their iCalUID is kept, others are inserted.
+// Responds with a batchResponse holding a result per VEVENT
+func ImportICS(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ImportICS")
+	defer span.End()
+
+	// Restrict method to post only
+	if r.Method != "POST" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if f, _, err := r.FormFile("file"); err == nil {
+		defer f.Close()
+		body = f
+	}
+	vevents, err := parseICS(body)
+	r.Body.Close()
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	res := []*batchResult{}
+	seen := map[string]bool{}
+	for idx, ve := range vevents {
+		ir := &batchResult{Index: idx, Status: http.StatusBadRequest}
+		res = append(res, ir)
+		if ve.Err != nil {
+			ir.Error = ve.Err.Error()
+			continue
+		}
+		evt, err := icsToEvent(ve)
+		if err != nil {
+			ir.Error = err.Error()
+			continue
+		}
+		ir.ICalUID = evt.ICalUID
+		if evt.ICalUID != "" {
+			if err := validICalUID(evt.ICalUID); err != nil {
+				ir.fail(err)
+				continue
+			}
+			if seen[evt.ICalUID] {
+				ir.Status = http.StatusConflict
+				ir.Error = "duplicate iCalUID in file"
+				continue
+			}
+			seen[evt.ICalUID] = true
+		}
+
+		var ev *calendar.Event
+		if evt.ICalUID != "" {
+			ev, err = srv.Events.Import(CalendarID, evt).Fields("id").Context(r.Context()).Do()
+		} else {
+			ev, err = srv.Events.Insert(CalendarID, evt).Fields("id").Context(r.Context()).Do()
+		}
+		if err != nil {
+			log.Println(err.Error())
+			ir.fail(err)
+			continue
+		}
+		ir.Status = http.StatusCreated
+		ir.ID = ev.Id
+	}
+	respond(w, r, http.StatusOK, &batchResponse{Results: res})
+}
+
+// ExportICS method responds with the events between the start and end query
+// params as an RFC 5545 .ics file. All-day events are written as DATE values,
+// so importers don't shift them, and timed events in UTC
+func ExportICS(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "ExportICS")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	start, end, err := timeRange(r, true)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var b bytes.Buffer
+	icsLine(&b, "BEGIN:VCALENDAR")
+	icsLine(&b, "VERSION:2.0")
+	icsLine(&b, "PRODID:-//pulpfree//google-cal-api//EN")
+	err = srv.Events.List(CalendarID).
+		ShowDeleted(ExportCancelled).
+		SingleEvents(true).
+		TimeMin(start.Format(time.RFC3339)).
+		TimeMax(end.Format(time.RFC3339)).
+		OrderBy("startTime").
+		Fields("nextPageToken,items(id,iCalUID,start,end,originalStartTime,recurringEventId,summary,description,location,updated,status,eventType)").
+		Pages(r.Context(), func(events *calendar.Events) error {
+			for _, i := range events.Items {
+				switch {
+				case i.Status == "cancelled" && i.OriginalStartTime != nil:
+					icsWriteCancelled(&b, i)
+				case i.Start != nil && i.End != nil:
+					icsWriteEvent(&b, i)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's events")
+		return
+	}
+	icsLine(&b, "END:VCALENDAR")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="calendar.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.Bytes())
+}
+
+// icsWriteEvent writes i as a VEVENT
+func icsWriteEvent(b *bytes.Buffer, i *calendar.Event) {
+	icsLine(b, "BEGIN:VEVENT")
+	icsLine(b, "UID:"+icsEscape(i.ICalUID))
+	if tm, err := time.Parse(time.RFC3339, i.Updated); err == nil {
+		icsLine(b, "DTSTAMP:"+tm.UTC().Format(tmICSUTC))
+	}
+	icsLine(b, icsDateProp("DTSTART", i.Start))
+	icsLine(b, icsDateProp("DTEND", i.End))
+	// Instances of a recurring event share its UID and are told apart by RECURRENCE-ID
+	if i.RecurringEventId != "" && i.OriginalStartTime != nil {
+		icsLine(b, icsDateProp("RECURRENCE-ID", i.OriginalStartTime))
+	}
+	if i.Summary != "" {
+		icsLine(b, "SUMMARY:"+icsEscape(i.Summary))
+	}
+	if i.Description != "" {
+		icsLine(b, "DESCRIPTION:"+icsEscape(i.Description))
+	}
+	if i.Location != "" {
+		icsLine(b, "LOCATION:"+icsEscape(i.Location))
+	}
+	if s, ok := icsStatuses[i.Status]; ok {
+		icsLine(b, "STATUS:"+s)
+	}
+	// No iCal equivalent, so kept as an extension for Google aware clients
+	if i.EventType != "" && i.EventType != "default" {
+		icsLine(b, "X-GOOGLE-EVENT-TYPE:"+i.EventType)
+	}
+	icsLine(b, "END:VEVENT")
+}
+
+// icsWriteCancelled writes a cancelled occurrence of a recurring event, of
+// which Google may only give the id, recurringEventId and originalStartTime,
+// as a stub VEVENT cancelling it. Without the iCalUID, the series' is taken
+// to be its id at google.com, as Google gives events created in Google
+func icsWriteCancelled(b *bytes.Buffer, i *calendar.Event) {
+	uid := i.ICalUID
+	if uid == "" {
+		uid = i.RecurringEventId + "@google.com"
+	}
+	icsLine(b, "BEGIN:VEVENT")
+	icsLine(b, "UID:"+icsEscape(uid))
+	if tm, err := time.Parse(time.RFC3339, i.Updated); err == nil {
+		icsLine(b, "DTSTAMP:"+tm.UTC().Format(tmICSUTC))
+	}
+	icsLine(b, icsDateProp("RECURRENCE-ID", i.OriginalStartTime))
+	icsLine(b, "STATUS:CANCELLED")
+	icsLine(b, "END:VEVENT")
+}
+
+// icsStatuses maps Google event statuses to the iCal STATUS of a VEVENT
+var icsStatuses = map[string]string{
+	"confirmed": "CONFIRMED",
+	"tentative": "TENTATIVE",
+	"cancelled": "CANCELLED",
+}
+
+// icsDateProp formats edt as a DATE for all-day events, otherwise as a UTC
+// DATE-TIME. Times aren't written with a TZID, which would need a VTIMEZONE
+// describing the zone's rules for importers to place them
+func icsDateProp(name string, edt *calendar.EventDateTime) string {
+	if edt.Date != "" {
+		tm, _ := time.Parse(tmLabelShort, edt.Date)
+		return name + ";VALUE=DATE:" + tm.Format(tmICSDate)
+	}
+	tm, _ := time.Parse(time.RFC3339, edt.DateTime)
+	return name + ":" + tm.UTC().Format(tmICSUTC)
+}
+
+// icsLine writes a content line, folded so no line exceeds 75 octets
+func icsLine(b *bytes.Buffer, ln string) {
+	limit := 75
+	for len(ln) > limit {
+		// Don't split a multi-byte UTF-8 character
+		n := limit
+		for n > 0 && !utf8.RuneStart(ln[n]) {
+			n--
+		}
+		b.WriteString(ln[:n] + "\r\n ")
+		ln = ln[n:]
+		// The leading space of a continuation line counts towards its length
+		limit = 74
+	}
+	b.WriteString(ln + "\r\n")
+}
+
+// parseICS unfolds the content lines and splits them into VEVENT blocks.
+// A malformed block is returned with Err set so the remainder can still be imported
+func parseICS(rd io.Reader) ([]*icsEvent, error) {
+	var lines []string
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		ln := strings.TrimRight(sc.Text(), "\r")
+		// Lines starting with a space or tab continue the previous line
+		if len(ln) > 0 && (ln[0] == ' ' || ln[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += ln[1:]
+			continue
+		}
+		if ln != "" {
+			lines = append(lines, ln)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, invalidRequest("not an iCalendar file")
+	}
+
+	var res []*icsEvent
+	var cur *icsEvent
+	depth := 0 // nesting inside the current VEVENT, e.g. VALARM
+	for _, ln := range lines {
+		p, err := parseICSLine(ln)
+		switch {
+		case cur == nil:
+			if err == nil && p.Name == "BEGIN" && strings.EqualFold(p.Value, "VEVENT") {
+				cur = &icsEvent{}
+			}
+		case err != nil:
+			if cur.Err == nil {
+				cur.Err = err
+			}
+		case p.Name == "BEGIN":
+			if strings.EqualFold(p.Value, "VEVENT") {
+				cur.Err = errors.New("unterminated VEVENT")
+				res = append(res, cur)
+				cur = &icsEvent{}
+				continue
+			}
+			depth++
+		case p.Name == "END":
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if !strings.EqualFold(p.Value, "VEVENT") && cur.Err == nil {
+				cur.Err = errors.New("unterminated VEVENT")
+			}
+			res = append(res, cur)
+			cur = nil
+		case depth == 0:
+			cur.Props = append(cur.Props, p)
+		}
+	}
+	if cur != nil {
+		cur.Err = errors.New("unterminated VEVENT")
+		res = append(res, cur)
+	}
+	return res, nil
+}
+
+// parseICSLine splits a content line into its name, parameters and value
+func parseICSLine(ln string) (icsProp, error) {
+	p := icsProp{Params: map[string]string{}}
+	// The value starts at the first colon outside of a quoted parameter
+	quoted := false
+	sep := -1
+	for i, c := range ln {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			sep = i
+			break
+		}
+	}
+	if sep < 1 {
+		return p, fmt.Errorf("malformed line: %q", ln)
+	}
+	p.Value = ln[sep+1:]
+	parts := strings.Split(ln[:sep], ";")
+	p.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return p, fmt.Errorf("malformed parameter: %q", param)
+		}
+		p.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+	return p, nil
+}
+
+// icsToEvent converts a VEVENT to a calendar.Event
+func icsToEvent(ve *icsEvent) (*calendar.Event, error) {
+	dtStart := ve.get("DTSTART")
+	if dtStart == nil {
+		return nil, errors.New("missing DTSTART")
+	}
+	start, err := icsDateTime(dtStart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART: %v", err)
+	}
+
+	var end *calendar.EventDateTime
+	if dtEnd := ve.get("DTEND"); dtEnd != nil {
+		end, err = icsDateTime(dtEnd)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DTEND: %v", err)
+		}
+	} else if start.Date != "" {
+		// An all-day event without DTEND lasts the one day, and Google's end date is exclusive
+		tm, _ := time.Parse(tmLabelShort, start.Date)
+		end = &calendar.EventDateTime{Date: tm.AddDate(0, 0, 1).Format(tmLabelShort)}
+	} else {
+		end = &calendar.EventDateTime{DateTime: start.DateTime, TimeZone: start.TimeZone}
+	}
+
+	evt := &calendar.Event{Start: start, End: end}
+	if p := ve.get("UID"); p != nil {
+		evt.ICalUID = p.Value
+	}
+	if p := ve.get("SUMMARY"); p != nil {
+		evt.Summary = icsUnescape(p.Value)
+	}
+	if p := ve.get("DESCRIPTION"); p != nil {
+		evt.Description = icsUnescape(p.Value)
+	}
+	if p := ve.get("LOCATION"); p != nil {
+		evt.Location = icsUnescape(p.Value)
+	}
+	for _, p := range ve.Props {
+		if p.Name == "RRULE" {
+			evt.Recurrence = append(evt.Recurrence, "RRULE:"+p.Value)
+		}
+	}
+	return evt, nil
+}
+
+// validICalUID checks uid is usable as an iCalUID: non-empty, at most 1024
+// characters, and free of whitespace and control characters
+func validICalUID(uid string) error {
+	if uid == "" || len(uid) > 1024 {
+		return invalidRequest("iCalUID must be 1 to 1024 characters")
+	}
+	for _, c := range uid {
+		if unicode.IsSpace(c) || unicode.IsControl(c) {
+			return invalidRequest("iCalUID must not contain whitespace or control characters")
+		}
+	}
+	return nil
+}
+
+// icsDateTime converts a DATE or DATE-TIME property value to an EventDateTime
+func icsDateTime(p *icsProp) (*calendar.EventDateTime, error) {
+	if p.Params["VALUE"] == "DATE" || len(p.Value) == len(tmICSDate) {
+		tm, err := time.Parse(tmICSDate, p.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &calendar.EventDateTime{Date: tm.Format(tmLabelShort)}, nil
+	}
+	if strings.HasSuffix(p.Value, "Z") {
+		tm, err := time.Parse(tmICSUTC, p.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &calendar.EventDateTime{DateTime: tm.Format(time.RFC3339)}, nil
+	}
+
+	// Floating times are taken to be in the configured location
+	l := loc
+	tzid := p.Params["TZID"]
+	if tzid != "" {
+		var err error
+		if l, err = time.LoadLocation(tzid); err != nil {
+			return nil, err
+		}
+	}
+	tm, err := time.ParseInLocation(tmICSDateTime, p.Value, l)
+	if err != nil {
+		return nil, err
+	}
+	return &calendar.EventDateTime{DateTime: tm.Format(time.RFC3339), TimeZone: tzid}, nil
+}
+
+var icsUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+// icsUnescape reverses the TEXT value escaping of RFC 5545
+func icsUnescape(s string) string {
+	return icsUnescaper.Replace(s)
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`)
+
+// icsEscape applies the TEXT value escaping of RFC 5545
+func icsEscape(s string) string {
+	return icsEscaper.Replace(strings.Replace(s, "\r\n", "\n", -1))
+}
@@ -0,0 +1,142 @@
+package calendar
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestICSDateProp(t *testing.T) {
+	tests := []struct {
+		name string
+		edt  calendar.EventDateTime
+		want string
+	}{
+		{"all-day", calendar.EventDateTime{Date: "2024-01-05"}, "DTSTART;VALUE=DATE:20240105"},
+		{"utc", calendar.EventDateTime{DateTime: "2024-01-05T10:00:00Z"}, "DTSTART:20240105T100000Z"},
+		{
+			"zoned",
+			calendar.EventDateTime{DateTime: "2024-07-05T09:00:00-04:00", TimeZone: "America/Toronto"},
+			"DTSTART:20240705T130000Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := icsDateProp("DTSTART", &tt.edt); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestICSRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	icsWriteEvent(&b, &calendar.Event{
+		ICalUID: "abc@google.com",
+		Summary: "Planning, Q3; " + strings.Repeat("é", 50),
+		Start:   &calendar.EventDateTime{DateTime: "2024-07-05T09:00:00-04:00", TimeZone: "America/Toronto"},
+		End:     &calendar.EventDateTime{DateTime: "2024-07-05T10:00:00-04:00", TimeZone: "America/Toronto"},
+	})
+	if strings.Contains(b.String(), "TZID") {
+		t.Errorf("export has a TZID without a VTIMEZONE:\n%s", b.String())
+	}
+	for _, ln := range strings.Split(b.String(), "\r\n") {
+		if len(ln) > 75 {
+			t.Errorf("line longer than 75 octets: %q", ln)
+		}
+	}
+
+	ves, err := parseICS(strings.NewReader("BEGIN:VCALENDAR\r\n" + b.String() + "END:VCALENDAR\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ves) != 1 {
+		t.Fatalf("got %d events, want 1", len(ves))
+	}
+	ev, err := icsToEvent(ves[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Summary != "Planning, Q3; "+strings.Repeat("é", 50) {
+		t.Errorf("summary = %q", ev.Summary)
+	}
+	if ev.Start.DateTime != "2024-07-05T13:00:00Z" {
+		t.Errorf("start = %s, want 2024-07-05T13:00:00Z", ev.Start.DateTime)
+	}
+}
+
+func TestICSStatus(t *testing.T) {
+	tests := []struct {
+		status, want string
+	}{
+		{"confirmed", "STATUS:CONFIRMED"},
+		{"tentative", "STATUS:TENTATIVE"},
+		{"cancelled", "STATUS:CANCELLED"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			var b bytes.Buffer
+			icsWriteEvent(&b, &calendar.Event{
+				ICalUID: "abc@google.com",
+				Status:  tt.status,
+				Start:   &calendar.EventDateTime{Date: "2024-01-10"},
+				End:     &calendar.EventDateTime{Date: "2024-01-11"},
+			})
+			got := ""
+			for _, ln := range strings.Split(b.String(), "\r\n") {
+				if strings.HasPrefix(ln, "STATUS:") {
+					got = ln
+				}
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportICSCancelledInstance(t *testing.T) {
+	defer func(c bool) { ExportCancelled = c }(ExportCancelled)
+	ExportCancelled = true
+	fakeEventPages(t, &calendar.Events{Items: []*calendar.Event{
+		timedEvent("abc_20240110T150000Z", "2024-01-10T15:00:00Z"),
+		// All Google guarantees of a deleted occurrence
+		{
+			Id:                "abc_20240117T150000Z",
+			Status:            "cancelled",
+			RecurringEventId:  "abc",
+			OriginalStartTime: &calendar.EventDateTime{DateTime: "2024-01-17T10:00:00-05:00"},
+		},
+	}})
+
+	w := httptest.NewRecorder()
+	ExportICS(w, httptest.NewRequest("GET", "/events/export.ics?start=2024-01-01&end=2024-02-01", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	ves, err := parseICS(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ves) != 2 {
+		t.Fatalf("got %d events, want 2", len(ves))
+	}
+	stub := ves[1]
+	for name, want := range map[string]string{
+		"UID":           "abc@google.com",
+		"RECURRENCE-ID": "20240117T150000Z",
+		"STATUS":        "CANCELLED",
+	} {
+		if p := stub.get(name); p == nil || p.Value != want {
+			t.Errorf("%s = %+v, want %s", name, p, want)
+		}
+	}
+	if stub.get("DTSTART") != nil {
+		t.Error("stub has a DTSTART Google didn't give")
+	}
+}
@@ -0,0 +1,74 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/calendar/v3"
+)
+
+// RecurringInstances method fetches the instances of the recurring event with
+// id, optionally limited to those between start and end, each tagged with the
+// id of its recurring master
+func RecurringInstances(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "RecurringInstances")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	vars := mux.Vars(r)
+	if vars["id"] == "" {
+		respondErr(w, r, http.StatusForbidden, "invalid request, missing event id")
+		return
+	}
+	start, end, err := timeRange(r, false)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	conv, err := eventConverter(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	master, err := srv.Events.Get(CalendarID, vars["id"]).Fields("recurrence").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if len(master.Recurrence) == 0 {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, not a recurring event")
+		return
+	}
+
+	if err := eventColors.refresh(r.Context()); err != nil {
+		log.Println(err.Error())
+	}
+	call := srv.Events.Instances(CalendarID, vars["id"]).ShowDeleted(false)
+	if !start.IsZero() {
+		call.TimeMin(start.Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		call.TimeMax(end.Format(time.RFC3339))
+	}
+	res := []*jEvent{}
+	err = call.Fields(listFields()).Pages(r.Context(), func(events *calendar.Events) error {
+		for _, i := range events.Items {
+			res = append(res, conv(i))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve event instances")
+		return
+	}
+	respond(w, r, http.StatusOK, res)
+}
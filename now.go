@@ -0,0 +1,100 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// CurrentEvents method fetches the events happening at the instant in the at
+// query param (RFC3339, now by default), those starting at or before it and
+// ending after it. Google only filters by range, so a day either side is
+// listed and the events are then checked against the instant, all-day events
+// covering their days from midnight in the tz location
+func CurrentEvents(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "CurrentEvents")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		tm, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, at must be an RFC3339 timestamp")
+			return
+		}
+		at = tm
+	}
+	l, err := tzParam(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	apply, err := listParams(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	filter, err := eventFilter(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	conv, err := eventConverter(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := eventColors.refresh(r.Context()); err != nil {
+		log.Println(err.Error())
+	}
+
+	call := srv.Events.List(CalendarID).
+		ShowDeleted(false).
+		SingleEvents(true).
+		TimeMin(at.AddDate(0, 0, -1).Format(time.RFC3339)).
+		TimeMax(at.AddDate(0, 0, 1).Format(time.RFC3339)).
+		OrderBy("startTime")
+	apply(call)
+	res := []*jEvent{}
+	err = call.Fields(listFields()).Pages(r.Context(), func(events *calendar.Events) error {
+		for _, i := range events.Items {
+			if filter(i) && happeningAt(i, at, l) {
+				res = append(res, conv(i))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's events")
+		return
+	}
+	respond(w, r, http.StatusOK, res)
+}
+
+// happeningAt reports whether at is within [start, end) of event i, the dates
+// of all-day events being taken as midnight in location l
+func happeningAt(i *calendar.Event, at time.Time, l *time.Location) bool {
+	if i.Start == nil || i.End == nil {
+		return false
+	}
+	parse := func(edt *calendar.EventDateTime) (time.Time, error) {
+		if edt.DateTime != "" {
+			return time.Parse(time.RFC3339, edt.DateTime)
+		}
+		return time.ParseInLocation(tmLabelShort, edt.Date, l)
+	}
+	st, err1 := parse(i.Start)
+	en, err2 := parse(i.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return !at.Before(st) && at.Before(en)
+}
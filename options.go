@@ -0,0 +1,133 @@
+package calendar
+
+import "time"
+
+// Options set by the deploying application, before serving requests
+
+var (
+	// ListCreator includes each listed event's creator
+	ListCreator = true
+	// ListOrganizer includes each listed event's organizer
+	ListOrganizer = true
+)
+
+// MaxRangeSpan is the longest start to end span accepted by handlers taking a
+// range, so a request can't page through decades of events
+var MaxRangeSpan = 366 * 24 * time.Hour
+
+// SanitizeDescriptions strips listed event descriptions down to the HTML in
+// DescriptionAllowlist, so browsers can render them safely. Trusted clients can
+// ask for the description as stored with ?rawDescription=true
+var SanitizeDescriptions = true
+
+// DescriptionAllowlist maps each HTML tag kept in sanitized descriptions to its
+// allowed attributes. It covers the formatting Google Calendar itself produces
+var DescriptionAllowlist = map[string][]string{
+	"a": {"href"}, "b": nil, "strong": nil, "i": nil, "em": nil, "u": nil,
+	"br": nil, "p": nil, "span": nil, "ul": nil, "ol": nil, "li": nil,
+}
+
+// ColorCacheTTL is how long the event color palette is cached before refetching
+var ColorCacheTTL = time.Hour
+
+// AccessRoleCacheTTL is how long the user's accessRole on a calendar, behind
+// the X-Calendar-Can-Edit header, is cached before refetching
+var AccessRoleCacheTTL = 5 * time.Minute
+
+// CalendarID is the calendar the handlers work on, "primary" being the
+// authenticated user's own calendar
+var CalendarID = "primary"
+
+// APIKeys are the credentials accepted by RequireAuth. When empty the
+// handlers are left open
+var APIKeys []string
+
+// CalendarConcurrency is how many calendars are fetched at once when
+// aggregating several, bounding the load on the API quota
+var CalendarConcurrency = 4
+
+var (
+	// WorkdayStart and WorkdayEnd bound the free slots FreeSlots returns
+	// each working day, as HH:MM in the tz location
+	WorkdayStart = "09:00"
+	WorkdayEnd   = "17:00"
+	// WorkingDays are the weekdays FreeSlots looks for free slots on
+	WorkingDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+)
+
+// AlwaysIncludeEmail has Google return an email for every listed attendee and
+// organizer, generating one where there's no real address, so the attendee
+// filter and response summaries see everyone. It's off by default as it can
+// expose addresses of attendees the user otherwise couldn't see
+var AlwaysIncludeEmail = false
+
+var (
+	// DelegationKeyFile is a service account JSON key with domain-wide
+	// delegation of the calendar scope. When set, createEvent acts as the user
+	// named by the subject header, so they're the new event's organizer
+	DelegationKeyFile string
+	// DelegationDomains are the domains a delegated subject may belong to,
+	// which should be the Workspace domains the delegation was granted in.
+	// Any client getting past RequireAuth can act as any user in them
+	DelegationDomains []string
+)
+
+var (
+	// RejectPastEvents has createEvent refuse events starting in the past
+	RejectPastEvents = false
+	// PastEventGrace is how far in the past a new event may start with
+	// RejectPastEvents, allowing for client clocks and slow submissions
+	PastEventGrace = 5 * time.Minute
+)
+
+// DefaultColor is the color name or id given to new events created without
+// one. When empty they take the calendar's color
+var DefaultColor string
+
+var (
+	// AllowedCalendars are the calendar ids clients may select, e.g. with the
+	// calendars param of AgendaEvents, besides CalendarID itself
+	AllowedCalendars = []string{"primary"}
+	// CalendarAllowed, when set, decides instead of AllowedCalendars which
+	// calendar ids clients may select
+	CalendarAllowed func(id string) bool
+)
+
+// MaxDescriptionLength is the longest description, in bytes, accepted for an
+// event, Google rejecting longer ones with an unhelpful error
+var MaxDescriptionLength = 8192
+
+// SkipUndatedEvents leaves listed events without a start or end out of
+// responses. Otherwise they're included with empty dates and undated set.
+// Cancelled events, which Google often lists without dates, are always kept
+// so UpdatedEvents still reports deletions
+var SkipUndatedEvents = false
+
+var (
+	// BreakerThreshold is how many Google calls in a row may fail before the
+	// circuit breaker opens, failing calls with 503 without making them. Zero
+	// disables the breaker
+	BreakerThreshold = 5
+	// BreakerCooldown is how long the breaker stays open before letting a
+	// trial call through to test whether Google has recovered
+	BreakerCooldown = 30 * time.Second
+)
+
+// ConfirmTokenTTL is how long the token given by previewing a destructive
+// operation, such as DeleteTaggedEvents, stays valid for confirming it
+var ConfirmTokenTTL = 5 * time.Minute
+
+// ExportCancelled includes cancelled events in ExportICS, with
+// STATUS:CANCELLED, so calendars importing the file remove them too.
+// Otherwise they're left out
+var ExportCancelled = false
+
+var (
+	// Timeout bounds the requests to handlers registered by RegisterRoutes,
+	// cancelling their Google calls once it passes. Zero means no limit
+	Timeout = 30 * time.Second
+	// ListTimeout and BatchTimeout override Timeout, when set, for handlers
+	// listing or exporting a range and those working on many events at once
+	ListTimeout  = 2 * time.Minute
+	BatchTimeout = 5 * time.Minute
+)
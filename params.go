@@ -0,0 +1,328 @@
+package calendar
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// listParams parses the optional query params shared by the listing handlers,
+// returning a func applying them to a list call: maxAttendees, tz, eventTypes
+// (comma separated, e.g. outOfOffice,focusTime) and sharedExtendedProperty
+// (key=value, repeatable, all must match). The filters are applied by Google
+// so every page, and each calendar of an agenda, is filtered alike.
+//
+// showHiddenInvitations=true includes the invitations Google keeps off the
+// calendar, such as those from unknown senders when the user's settings only
+// add invitations from known senders
+func listParams(r *http.Request) (func(*calendar.EventsListCall), error) {
+	n, err := maxAttendees(r)
+	if err != nil {
+		return nil, err
+	}
+	tz := r.URL.Query().Get("tz")
+	if _, err := tzParam(r); err != nil {
+		return nil, err
+	}
+	var types []string
+	if v := r.URL.Query().Get("eventTypes"); v != "" {
+		types = strings.Split(v, ",")
+		for _, t := range types {
+			if !contains(eventTypes, t) {
+				return nil, invalidRequest("eventTypes must be among " + strings.Join(eventTypes, ", "))
+			}
+		}
+	}
+	hidden := false
+	if v := r.URL.Query().Get("showHiddenInvitations"); v != "" {
+		if hidden, err = strconv.ParseBool(v); err != nil {
+			return nil, invalidRequest("showHiddenInvitations must be true or false")
+		}
+	}
+	shared := r.URL.Query()["sharedExtendedProperty"]
+	for _, v := range shared {
+		if err := validPropertyFilter(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(call *calendar.EventsListCall) {
+		if n > 0 {
+			call.MaxAttendees(n)
+		}
+		if tz != "" {
+			call.TimeZone(tz)
+		}
+		if types != nil {
+			call.EventTypes(types...)
+		}
+		if shared != nil {
+			call.SharedExtendedProperty(shared...)
+		}
+		if AlwaysIncludeEmail {
+			call.AlwaysIncludeEmail(true)
+		}
+		if hidden {
+			call.ShowHiddenInvitations(true)
+		}
+	}, nil
+}
+
+// eventTypes are the event types Google accepts in the eventTypes filter
+var eventTypes = []string{"default", "outOfOffice", "focusTime", "workingLocation", "birthday", "fromGmail"}
+
+// tzParam returns the location named by the tz query param, e.g.
+// America/Vancouver, or the configured location when unset. Google
+// then returns event times in that zone rather than the calendar's
+func tzParam(r *http.Request) (*time.Location, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return loc, nil
+	}
+	l, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, invalidRequest("unknown tz: " + tz)
+	}
+	return l, nil
+}
+
+// maxAttendees parses the maxAttendees query param, returning 0 when unset.
+// When an event has more attendees Google returns only the participant and sets
+// attendeesOmitted, so the responseSummary then only counts what was returned
+func maxAttendees(r *http.Request) (int64, error) {
+	v := r.URL.Query().Get("maxAttendees")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 1 {
+		return 0, invalidRequest("maxAttendees must be a positive integer")
+	}
+	return n, nil
+}
+
+// listFiltered reports whether the listing is narrowed by any filter, by
+// Google or after fetching, so it isn't the full listing of its range
+func listFiltered(r *http.Request) bool {
+	q := r.URL.Query()
+	for _, name := range []string{"attendee", "location", "eventTypes", "sharedExtendedProperty"} {
+		if _, ok := q[name]; ok {
+			return true
+		}
+	}
+	if v := q.Get("declined"); v != "" && v != "include" {
+		return true
+	}
+	return SkipUndatedEvents
+}
+
+// filterFields are the event fields eventFilter looks at, all a pass that
+// only filters needs to request
+const filterFields = "id,attendees,location,start,end"
+
+// maxLocationFilter is the longest location query param eventFilter accepts
+const maxLocationFilter = 256
+
+// eventFilter builds the filter applied to listed events after they're fetched,
+// for the query params Google can't filter on server side:
+//
+//	attendee=email	only events with that attendee (affected by maxAttendees truncation)
+//	declined=exclude	hide events the user declined, or only to keep just those
+//	location=text	only events whose location contains text, ignoring case
+//
+// Declined events are found from the user's own attendee entry, so events the
+// user isn't invited to are never counted as declined
+func eventFilter(r *http.Request) (func(*calendar.Event) bool, error) {
+	var filters []func(*calendar.Event) bool
+	if v := r.URL.Query().Get("attendee"); v != "" {
+		addr, err := mail.ParseAddress(v)
+		if err != nil {
+			return nil, invalidRequest("attendee must be an email address")
+		}
+		filters = append(filters, func(i *calendar.Event) bool {
+			return hasAttendee(i.Attendees, addr.Address)
+		})
+	}
+	if v, ok := r.URL.Query()["location"]; ok {
+		loc := strings.ToLower(strings.TrimSpace(v[0]))
+		if loc == "" || len(loc) > maxLocationFilter {
+			return nil, invalidRequest(fmt.Sprintf("location must be between 1 and %d characters", maxLocationFilter))
+		}
+		filters = append(filters, func(i *calendar.Event) bool {
+			return strings.Contains(strings.ToLower(i.Location), loc)
+		})
+	}
+	switch v := r.URL.Query().Get("declined"); v {
+	case "", "include":
+	case "exclude", "only":
+		only := v == "only"
+		filters = append(filters, func(i *calendar.Event) bool {
+			return selfDeclined(i.Attendees) == only
+		})
+	default:
+		return nil, invalidRequest("declined must be include, exclude or only")
+	}
+	if SkipUndatedEvents {
+		filters = append(filters, func(i *calendar.Event) bool {
+			return i.Status == "cancelled" || i.Start != nil && i.End != nil
+		})
+	}
+	return func(i *calendar.Event) bool {
+		for _, f := range filters {
+			if !f(i) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// timeRange parses the start and end query params, each an RFC3339 timestamp or
+// a YYYY-MM-DD date taken as midnight in the tz location. Zero times are
+// returned for params that aren't set unless required. When both are set the
+// span is limited to MaxRangeSpan
+func timeRange(r *http.Request, required bool) (time.Time, time.Time, error) {
+	l, err := tzParam(r)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	var tms [2]time.Time
+	for idx, name := range []string{"start", "end"} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			if required {
+				return time.Time{}, time.Time{}, invalidRequest("missing " + name)
+			}
+			continue
+		}
+		tm, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			tm, err = time.ParseInLocation(tmLabelShort, v, l)
+		}
+		if err != nil {
+			return time.Time{}, time.Time{}, invalidRequest(name + " must be RFC3339 or YYYY-MM-DD")
+		}
+		tms[idx] = tm
+	}
+	if !tms[0].IsZero() && !tms[1].IsZero() && !tms[1].After(tms[0]) {
+		return time.Time{}, time.Time{}, invalidRequest("end must be after start")
+	}
+	if !tms[0].IsZero() && !tms[1].IsZero() && tms[1].Sub(tms[0]) > MaxRangeSpan {
+		return time.Time{}, time.Time{}, invalidRequest(fmt.Sprintf("range can't exceed %d days", int(MaxRangeSpan.Hours()/24)))
+	}
+	return tms[0], tms[1], nil
+}
+
+// validPropertyFilter checks an extended property filter is of the form key=value
+func validPropertyFilter(v string) error {
+	kv := strings.SplitN(v, "=", 2)
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return invalidRequest("extended property filter must be key=value")
+	}
+	return nil
+}
+
+// eventConverter returns the conversion to jEvent for the output options in
+// the query params of the listing handlers:
+//
+//	rawDescription=true	skip sanitizing descriptions, for trusted clients
+//	timeFormat=layout	format dates as rfc3339 (default), unixMillis or a Go layout
+//	altTz=zone	also give each timed event's start in zone as dateAlt, e.g. for travel
+//	recurrenceText=true	describe the rule of recurring masters, e.g. "Every Monday"
+//
+// Expanded instances carry no rule, so recurrenceText is only set for masters,
+// as UpdatedEvents lists
+func eventConverter(r *http.Request) (func(*calendar.Event) *jEvent, error) {
+	raw := false
+	if v := r.URL.Query().Get("rawDescription"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, invalidRequest("rawDescription must be true or false")
+		}
+		raw = b
+	}
+	format, err := timeFormat(r)
+	if err != nil {
+		return nil, err
+	}
+	l, err := tzParam(r)
+	if err != nil {
+		return nil, err
+	}
+	recText := false
+	if v := r.URL.Query().Get("recurrenceText"); v != "" {
+		if recText, err = strconv.ParseBool(v); err != nil {
+			return nil, invalidRequest("recurrenceText must be true or false")
+		}
+	}
+	var alt *time.Location
+	if v := r.URL.Query().Get("altTz"); v != "" {
+		if alt, err = time.LoadLocation(v); err != nil {
+			return nil, invalidRequest("unknown altTz: " + v)
+		}
+	}
+	return func(i *calendar.Event) *jEvent {
+		ev := toJEvent(i, &eventColors, l)
+		if SanitizeDescriptions && !raw {
+			ev.Description = sanitizeHTML(ev.Description)
+		}
+		if recText && len(i.Recurrence) > 0 {
+			ev.RecurrenceText = recurrenceText(i.Recurrence)
+		}
+		// All-day events have no instant to show elsewhere
+		if alt != nil && ev.Date != "" && !ev.AllDay {
+			tm := ev.start.In(alt)
+			ev.DateAlt = tm.Format(time.RFC3339)
+			if format != nil {
+				ev.DateAlt = format(tm)
+			}
+		}
+		if format != nil && ev.Date != "" {
+			ev.Date = format(ev.start)
+		}
+		return ev
+	}, nil
+}
+
+// timeFormat parses the timeFormat query param, returning nil for the default
+// RFC3339. unixMillis gives the milliseconds since the epoch, as a string like
+// the other formats, and anything else must be a Go time layout
+func timeFormat(r *http.Request) (func(time.Time) string, error) {
+	v := r.URL.Query().Get("timeFormat")
+	switch v {
+	case "", "rfc3339":
+		return nil, nil
+	case "unixMillis":
+		return func(tm time.Time) string {
+			return strconv.FormatInt(tm.UnixNano()/int64(time.Millisecond), 10)
+		}, nil
+	}
+	// A layout without any elements formats every time the same
+	ref := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	s := ref.Format(v)
+	if s == v {
+		return nil, invalidRequest("timeFormat must be rfc3339, unixMillis or a Go time layout")
+	}
+	if _, err := time.Parse(v, s); err != nil {
+		return nil, invalidRequest("timeFormat layout can't be parsed: " + err.Error())
+	}
+	return func(tm time.Time) string { return tm.Format(v) }, nil
+}
+
+// singleScope parses the scope query param of the updating handlers, where
+// scope=single insists the id is a recurring instance, so only that
+// occurrence changes. Google then keeps it as an exception to the series
+func singleScope(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("scope") {
+	case "":
+		return false, nil
+	case "single":
+		return true, nil
+	}
+	return false, invalidRequest("scope must be single")
+}
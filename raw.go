@@ -0,0 +1,42 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// mergeRaw merges the fields of evt, assembled from a newEvent, over raw, a
+// partial Event as Google's JSON, so clients can set fields newEvent doesn't
+// model. Fields evt sets, including ones it forces or nulls, take precedence.
+// raw must be an object of Event fields only, so typos aren't silently dropped
+func mergeRaw(evt *calendar.Event, raw json.RawMessage) (*calendar.Event, error) {
+	base := &calendar.Event{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(base); err != nil {
+		return nil, invalidRequest("raw must be a partial event: " + err.Error())
+	}
+
+	// Whole fields are copied, rather than the JSON merged, to keep the
+	// ForceSendFields and NullFields of nested values like Reminders
+	set := map[string]bool{}
+	for _, f := range append(evt.ForceSendFields, evt.NullFields...) {
+		set[f] = true
+	}
+	src, dst := reflect.ValueOf(evt).Elem(), reflect.ValueOf(base).Elem()
+	for idx := 0; idx < src.NumField(); idx++ {
+		name := src.Type().Field(idx).Name
+		if name == "ForceSendFields" || name == "NullFields" {
+			continue
+		}
+		if set[name] || !src.Field(idx).IsZero() {
+			dst.Field(idx).Set(src.Field(idx))
+		}
+	}
+	base.ForceSendFields = append(base.ForceSendFields, evt.ForceSendFields...)
+	base.NullFields = append(base.NullFields, evt.NullFields...)
+	return base, nil
+}
@@ -0,0 +1,158 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rruleDays = map[string]string{
+	"MO": "Monday", "TU": "Tuesday", "WE": "Wednesday", "TH": "Thursday",
+	"FR": "Friday", "SA": "Saturday", "SU": "Sunday",
+}
+
+var rruleOrdinals = map[string]string{
+	"1": "first", "2": "second", "3": "third", "4": "fourth", "5": "fifth", "-1": "last",
+}
+
+var rruleUnits = map[string]string{
+	"DAILY": "day", "WEEKLY": "week", "MONTHLY": "month", "YEARLY": "year",
+}
+
+// recurrenceText describes the RRULE among an event's recurrence lines, e.g.
+// "Every 2 weeks on Monday and Wednesday, 10 times". Only the common FREQ,
+// INTERVAL, BYDAY, BYMONTHDAY, COUNT and UNTIL parts are described, the rule
+// itself being returned for anything else. EXDATE and RDATE lines are ignored
+func recurrenceText(recurrence []string) string {
+	var rules []string
+	for _, line := range recurrence {
+		if strings.HasPrefix(line, "RRULE:") {
+			rules = append(rules, line)
+		}
+	}
+	if len(rules) != 1 {
+		return strings.Join(rules, "\n")
+	}
+	if s, ok := describeRRule(strings.TrimPrefix(rules[0], "RRULE:")); ok {
+		return s
+	}
+	return rules[0]
+}
+
+// describeRRule describes an RRULE value, reporting false when it can't
+func describeRRule(rule string) (string, bool) {
+	parts := map[string]string{}
+	for _, p := range strings.Split(rule, ";") {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return "", false
+		}
+		parts[kv[0]] = kv[1]
+	}
+	unit, ok := rruleUnits[parts["FREQ"]]
+	if !ok {
+		return "", false
+	}
+	interval := 1
+	if v, ok := parts["INTERVAL"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return "", false
+		}
+		interval = n
+	}
+	for k := range parts {
+		switch k {
+		case "FREQ", "INTERVAL", "BYDAY", "BYMONTHDAY", "COUNT", "UNTIL", "WKST":
+		default:
+			return "", false
+		}
+	}
+
+	s := "Every " + unit
+	if interval > 1 {
+		s = fmt.Sprintf("Every %d %ss", interval, unit)
+	}
+	switch {
+	case parts["BYDAY"] != "" && parts["BYMONTHDAY"] != "":
+		return "", false
+	case parts["BYDAY"] != "":
+		// Days without an ordinal only read naturally for weekly rules; a
+		// monthly or daily one would mean every such day in the period
+		if unit != "week" && unit != "month" {
+			return "", false
+		}
+		days, ok := describeByDay(parts["BYDAY"], unit == "month")
+		if !ok {
+			return "", false
+		}
+		if days == "weekdays" && interval == 1 {
+			s = "Every weekday"
+		} else if unit == "week" && interval == 1 {
+			s = "Every " + days
+		} else {
+			s += " on " + days
+		}
+	case parts["BYMONTHDAY"] != "":
+		if unit != "month" {
+			return "", false
+		}
+		s += " on day " + strings.Replace(parts["BYMONTHDAY"], ",", ", ", -1)
+	}
+
+	if v := parts["COUNT"]; v != "" {
+		if _, err := strconv.Atoi(v); err != nil {
+			return "", false
+		}
+		s += ", " + v + " times"
+	}
+	if v := parts["UNTIL"]; v != "" {
+		// The date part of a DATE or DATE-TIME
+		if len(v) > len(tmICSDate) {
+			v = v[:len(tmICSDate)]
+		}
+		tm, err := time.Parse(tmICSDate, v)
+		if err != nil {
+			return "", false
+		}
+		s += ", until " + tm.Format(tmLabelShort)
+	}
+	return s, true
+}
+
+// describeByDay describes a BYDAY list, like "Monday and Wednesday". Monthly
+// rules must prefix each day with its ordinal in the month, e.g. -1FR, and
+// weekly ones can't
+func describeByDay(v string, monthly bool) (string, bool) {
+	codes := strings.Split(v, ",")
+	if v == "MO,TU,WE,TH,FR" && !monthly {
+		return "weekdays", true
+	}
+	var names []string
+	for _, c := range codes {
+		if len(c) < 2 {
+			return "", false
+		}
+		ord, day := c[:len(c)-2], c[len(c)-2:]
+		name, ok := rruleDays[day]
+		if !ok {
+			return "", false
+		}
+		if (ord != "") != monthly {
+			return "", false
+		}
+		if monthly {
+			o, ok := rruleOrdinals[strings.TrimPrefix(ord, "+")]
+			if !ok {
+				return "", false
+			}
+			name = "the " + o + " " + name
+		}
+		names = append(names, name)
+	}
+	if len(names) == 1 {
+		return names[0], true
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1], true
+}
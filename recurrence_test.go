@@ -0,0 +1,35 @@
+package calendar
+
+import "testing"
+
+func TestRecurrenceText(t *testing.T) {
+	tests := []struct {
+		rule, want string
+	}{
+		{"RRULE:FREQ=DAILY", "Every day"},
+		{"RRULE:FREQ=DAILY;INTERVAL=3;COUNT=5", "Every 3 days, 5 times"},
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,WE", "Every Monday and Wednesday"},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR", "Every 2 weeks on Monday, Wednesday and Friday"},
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", "Every weekday"},
+		{"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU,WE,TH,FR", "Every 2 weeks on weekdays"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=-1FR", "Every month on the last Friday"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=1MO,3MO", "Every month on the first Monday and the third Monday"},
+		{"RRULE:FREQ=MONTHLY;BYMONTHDAY=1,15", "Every month on day 1, 15"},
+		{"RRULE:FREQ=YEARLY;UNTIL=20301231T000000Z", "Every year, until 2030-12-31"},
+		// Read naturally these would mean something else, so the rule is kept
+		{"RRULE:FREQ=MONTHLY;BYDAY=MO", "RRULE:FREQ=MONTHLY;BYDAY=MO"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR", "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"RRULE:FREQ=MONTHLY;BYDAY=1MO,TU", "RRULE:FREQ=MONTHLY;BYDAY=1MO,TU"},
+		{"RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", "RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR"},
+		{"RRULE:FREQ=YEARLY;BYDAY=MO", "RRULE:FREQ=YEARLY;BYDAY=MO"},
+		{"RRULE:FREQ=WEEKLY;BYDAY=1MO", "RRULE:FREQ=WEEKLY;BYDAY=1MO"},
+		{"RRULE:FREQ=WEEKLY;BYSETPOS=1", "RRULE:FREQ=WEEKLY;BYSETPOS=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			if got := recurrenceText([]string{tt.rule, "EXDATE:20240110T090000Z"}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package calendar
+
+import (
+	"fmt"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+// Google's limits for reminder overrides
+const (
+	maxReminders       = 5
+	maxReminderMinutes = 40320 // four weeks
+)
+
+// newReminder is a reminder override submitted with a newEvent
+type newReminder struct {
+	Method  string
+	Minutes int64
+}
+
+// assembleReminders validates reminder overrides against Google's limits,
+// which Google would otherwise reject with an opaque error
+func assembleReminders(list []newReminder) (*calendar.EventReminders, error) {
+	if len(list) > maxReminders {
+		return nil, invalidRequest(fmt.Sprintf("at most %d reminders are allowed", maxReminders))
+	}
+	rems := &calendar.EventReminders{
+		Overrides:       []*calendar.EventReminder{},
+		ForceSendFields: []string{"UseDefault"},
+	}
+	for _, rm := range list {
+		if rm.Method != "email" && rm.Method != "popup" {
+			return nil, invalidRequest("reminder method must be email or popup: " + rm.Method)
+		}
+		if rm.Minutes < 0 || rm.Minutes > maxReminderMinutes {
+			return nil, invalidRequest(fmt.Sprintf("reminder minutes must be between 0 and %d", maxReminderMinutes))
+		}
+		rems.Overrides = append(rems.Overrides, &calendar.EventReminder{
+			Method:          rm.Method,
+			Minutes:         rm.Minutes,
+			ForceSendFields: []string{"Minutes"},
+		})
+	}
+	return rems, nil
+}
@@ -0,0 +1,57 @@
+package calendar
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestNoReminders(t *testing.T) {
+	evt, err := assembleEvent(&newEvent{Summary: "Focus", NoReminders: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(evt.Reminders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"overrides":[],"useDefault":false}`; got != want {
+		t.Errorf("reminders = %s, want %s", got, want)
+	}
+
+	if _, err := assembleEvent(&newEvent{NoReminders: true, Reminders: []newReminder{{Method: "popup"}}}); err == nil {
+		t.Error("want an error setting reminders with noReminders")
+	}
+}
+
+func TestAssembleReminders(t *testing.T) {
+	five := make([]newReminder, maxReminders)
+	for i := range five {
+		five[i] = newReminder{Method: "popup", Minutes: int64(i * 10)}
+	}
+	rems, err := assembleReminders(five)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rems.Overrides) != maxReminders || rems.UseDefault {
+		t.Errorf("got %d overrides, useDefault %v", len(rems.Overrides), rems.UseDefault)
+	}
+
+	tests := []struct {
+		name string
+		list []newReminder
+	}{
+		{"six", append(five, newReminder{Method: "email", Minutes: 60})},
+		{"invalid method", []newReminder{{Method: "sms", Minutes: 10}}},
+		{"negative minutes", []newReminder{{Method: "email", Minutes: -1}}},
+		{"over four weeks", []newReminder{{Method: "email", Minutes: maxReminderMinutes + 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := assembleReminders(tt.list)
+			if aerr, ok := err.(*apiError); !ok || aerr.Status != http.StatusBadRequest {
+				t.Errorf("err = %v, want a 400 apiError", err)
+			}
+		})
+	}
+}
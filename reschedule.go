@@ -0,0 +1,107 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/calendar/v3"
+)
+
+// reschedule is the new timing submitted to RescheduleEvent: Start and End
+// (RFC3339) to make it a timed event at those times, or like cloneShift an
+// Offset or Date to move it keeping its duration and whether it's all-day
+type reschedule struct {
+	Start  string `json:"start"`
+	End    string `json:"end"`
+	Offset string `json:"offset"`
+	Date   string `json:"date"`
+}
+
+// RescheduleEvent method moves the event with id, patching only its start and
+// end so the summary, description and attendees are left alone. With
+// scope=single the id must be a recurring instance, which is moved alone
+func RescheduleEvent(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "RescheduleEvent")
+	defer span.End()
+
+	// Restrict method to patch only
+	if r.Method != "PATCH" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+	vars := mux.Vars(r)
+	if vars["id"] == "" {
+		respondErr(w, r, http.StatusForbidden, "invalid request, missing event id")
+		return
+	}
+	single, err := singleScope(r)
+	if err != nil {
+		respondError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	var req reschedule
+	if err := decodeBody(r, &req); err != nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	ev, err := srv.Events.Get(CalendarID, vars["id"]).Fields("start,end,recurringEventId,sequence").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusNotFound, err)
+		return
+	}
+	if single && ev.RecurringEventId == "" {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, scope single needs a recurring event instance")
+		return
+	}
+	if ev.Start == nil || ev.End == nil {
+		respondErr(w, r, http.StatusBadRequest, "invalid request, event has no start or end to move")
+		return
+	}
+
+	patch := &calendar.Event{Sequence: ev.Sequence + 1, ForceSendFields: []string{"Sequence"}}
+	switch {
+	case req.Start != "" || req.End != "":
+		if req.Offset != "" || req.Date != "" {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, start and end can't be set with offset or date")
+			return
+		}
+		st, err := time.Parse(time.RFC3339, req.Start)
+		if err != nil {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, start must be RFC3339")
+			return
+		}
+		end, err := time.Parse(time.RFC3339, req.End)
+		if err != nil {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, end must be RFC3339")
+			return
+		}
+		if !end.After(st) {
+			respondErr(w, r, http.StatusBadRequest, "invalid request, end must be after start")
+			return
+		}
+		// Null the date so an all-day event becomes a timed one
+		patch.Start = &calendar.EventDateTime{DateTime: req.Start, NullFields: []string{"Date"}}
+		patch.End = &calendar.EventDateTime{DateTime: req.End, NullFields: []string{"Date"}}
+	case req.Offset != "" || req.Date != "":
+		if err := shiftEvent(ev, &cloneShift{Offset: req.Offset, Date: req.Date}); err != nil {
+			respondError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		patch.Start, patch.End = ev.Start, ev.End
+	default:
+		respondErr(w, r, http.StatusBadRequest, "invalid request, missing start and end, offset or date")
+		return
+	}
+
+	res, err := srv.Events.Patch(CalendarID, vars["id"], patch).Fields("id,start,end").Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	respond(w, r, http.StatusOK, res)
+}
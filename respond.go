@@ -1,9 +1,13 @@
 package calendar
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 )
 
 func decodeBody(r *http.Request, v interface{}) error {
@@ -14,25 +18,74 @@ func encodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
 	return json.NewEncoder(w).Encode(v)
 }
 
+// respond marshals data before writing anything, so a value that can't be
+// encoded gets a 500 error envelope rather than a half written response.
+// Keys are camelCase unless the request asks for ?format=snake
 func respond(w http.ResponseWriter, r *http.Request,
 	status int, data interface{},
 ) {
+	if data == nil {
+		w.WriteHeader(status)
+		return
+	}
+	b, err := encodeJSON(r, data)
+	if err != nil {
+		log.Println(err.Error())
+		status = http.StatusInternalServerError
+		b, _ = json.Marshal(errEnvelope("unable to encode response"))
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// respondETag responds like respond, with a strong ETag hashed from the encoded
+// data. A request whose If-None-Match holds that ETag gets 304 Not Modified
+func respondETag(w http.ResponseWriter, r *http.Request,
+	status int, data interface{},
+) {
+	b, err := encodeJSON(r, data)
+	if err != nil {
+		respond(w, r, status, data)
+		return
+	}
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	for _, m := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if m = strings.TrimSpace(m); m == etag || m == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(status)
-	if data != nil {
-		encodeBody(w, r, data)
+	w.Write(b)
+}
+
+// encodeJSON marshals v with the key format the request asked for
+func encodeJSON(r *http.Request, v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if _, ok := v.(dataKeyed); !ok && err == nil && r.URL.Query().Get("format") == "snake" {
+		b, err = toSnakeJSON(b)
 	}
+	return b, err
 }
 func respondErr(w http.ResponseWriter, r *http.Request,
 	status int, args ...interface{},
 ) {
-	respond(w, r, status, map[string]interface{}{
-		"error": map[string]interface{}{
-			"message": fmt.Sprint(args...),
-		},
-	})
+	respond(w, r, status, errEnvelope(args...))
 }
 func respondHTTPErr(w http.ResponseWriter, r *http.Request,
 	status int,
 ) {
 	respondErr(w, r, status, http.StatusText(status))
 }
+
+func errEnvelope(args ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": fmt.Sprint(args...),
+		},
+	}
+}
@@ -0,0 +1,48 @@
+package calendar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRespondSnakeKeepsDataKeys(t *testing.T) {
+	r := httptest.NewRequest("GET", "/events/colors?format=snake", nil)
+	w := httptest.NewRecorder()
+	respond(w, r, http.StatusOK, colorCounts{"Tomato": 4, uncoloredKey: 1})
+	if got, want := strings.TrimSpace(w.Body.String()), `{"Tomato":4,"default":1}`; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	respond(w, r, http.StatusOK, &responseSummary{NeedsAction: 2})
+	if !strings.Contains(w.Body.String(), `"needs_action":2`) {
+		t.Errorf("body = %s, want snake_case keys", w.Body.String())
+	}
+}
+
+func TestRespondUnmarshalable(t *testing.T) {
+	w := httptest.NewRecorder()
+	respond(w, httptest.NewRequest("GET", "/", nil), http.StatusOK, map[string]interface{}{"ch": make(chan int)})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if got, want := w.Body.String(), `{"error":{"message":"unable to encode response"}}`; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+func TestRespondContentType(t *testing.T) {
+	w := httptest.NewRecorder()
+	respond(w, httptest.NewRequest("GET", "/", nil), http.StatusCreated, map[string]string{"id": "abc"})
+	if w.Code != http.StatusCreated {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
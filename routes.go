@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+)
+
+// routePrefix is the prefix handlers were registered under by RegisterRoutes,
+// used to build Location URLs
+var routePrefix string
+
+// RegisterRoutes registers all handlers on r under prefix, e.g. "/api/calendar".
+// The handlers remain exported for wiring routes by hand
+func RegisterRoutes(r *mux.Router, prefix string) {
+	routePrefix = prefix
+	s := r.PathPrefix(prefix).Subrouter()
+	s.Use(RequireAuth)
+	s.Use(RequireService)
+	s.HandleFunc("/events/updated", withTimeout(opList, UpdatedEvents))
+	s.HandleFunc("/events/upcoming", withTimeout(opList, UpcomingEvents))
+	s.HandleFunc("/events/now", withTimeout(opList, CurrentEvents))
+	s.HandleFunc("/events/agenda", withTimeout(opList, AgendaEvents))
+	s.HandleFunc("/events/relative/{range}", withTimeout(opList, RelativeEvents))
+	s.HandleFunc("/events/bulk", withTimeout(opBatch, BulkPatchEvents))
+	s.HandleFunc("/events/batch", withTimeout(opBatch, BulkFetchEvents))
+	s.HandleFunc("/events/tagged", withTimeout(opBatch, DeleteTaggedEvents))
+	s.HandleFunc("/events/import", withTimeout(opBatch, ImportICS))
+	s.HandleFunc("/events/export.ics", withTimeout(opList, ExportICS))
+	s.HandleFunc("/events/export.csv", withTimeout(opList, ExportCSV))
+	s.HandleFunc("/events/colors", withTimeout(opList, ColorCounts))
+	s.HandleFunc("/events/{date:[0-9]{6}}", withTimeout(opList, MonthEvents))
+	s.HandleFunc("/events/{date:[0-9]{8}}", withTimeout(opList, DayEvents))
+	s.HandleFunc("/event", withTimeout(opSingle, Event))
+	s.HandleFunc("/event/{id}", withTimeout(opSingle, Event))
+	s.HandleFunc("/event/{id}/clone", withTimeout(opSingle, CloneEvent))
+	s.HandleFunc("/event/{id}/instances", withTimeout(opList, RecurringInstances))
+	s.HandleFunc("/event/{id}/reschedule", withTimeout(opSingle, RescheduleEvent))
+	s.HandleFunc("/event/{id}/attendees/{email}", withTimeout(opSingle, RemoveAttendee))
+	s.HandleFunc("/freeslots", withTimeout(opList, FreeSlots))
+	s.HandleFunc("/primary", withTimeout(opSingle, PrimaryCalendar))
+	s.HandleFunc("/calendars/{id}", withTimeout(opSingle, GetCalendar)).Methods("GET")
+	s.HandleFunc("/calendars/{id}", withTimeout(opSingle, UpdateCalendar)).Methods("PATCH")
+	s.HandleFunc("/calendars/{id}/clear", withTimeout(opBatch, ClearCalendar))
+	s.HandleFunc("/settings", withTimeout(opSingle, UserSettings))
+	s.HandleFunc("/resources", withTimeout(opList, ResourceCalendars))
+}
+
+// eventURL returns the URL of the event with id
+func eventURL(id string) string {
+	return routePrefix + "/event/" + id
+}
+
+// Kinds of handler, for their timeouts
+const (
+	opSingle = iota // working on one event or setting
+	opList          // listing or exporting a range
+	opBatch         // working on many events
+)
+
+// timeoutFor returns the timeout of op handlers
+func timeoutFor(op int) time.Duration {
+	switch {
+	case op == opList && ListTimeout > 0:
+		return ListTimeout
+	case op == opBatch && BatchTimeout > 0:
+		return BatchTimeout
+	}
+	return Timeout
+}
+
+// withTimeout bounds the request context of h by the timeout of op, looked up
+// on each request so the options can be changed after registering
+func withTimeout(op int, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		d := timeoutFor(op)
+		if d <= 0 {
+			h(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		h(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,62 @@
+package calendar
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestTimeoutFor(t *testing.T) {
+	defer func(s, l, b time.Duration) { Timeout, ListTimeout, BatchTimeout = s, l, b }(Timeout, ListTimeout, BatchTimeout)
+	Timeout, ListTimeout, BatchTimeout = time.Second, time.Minute, time.Hour
+
+	for op, want := range map[int]time.Duration{opSingle: time.Second, opList: time.Minute, opBatch: time.Hour} {
+		if got := timeoutFor(op); got != want {
+			t.Errorf("op %d: timeout = %s, want %s", op, got, want)
+		}
+	}
+	// Unset kinds fall back to Timeout
+	ListTimeout, BatchTimeout = 0, 0
+	if got := timeoutFor(opList); got != time.Second {
+		t.Errorf("unset list timeout = %s, want %s", got, time.Second)
+	}
+}
+
+func TestExportUsesListTimeout(t *testing.T) {
+	defer func(s, l time.Duration, p string) {
+		Timeout, ListTimeout, routePrefix = s, l, p
+	}(Timeout, ListTimeout, routePrefix)
+	Timeout, ListTimeout = 50*time.Millisecond, 5*time.Second
+
+	// Google answers slower than Timeout but well within ListTimeout
+	fakeGoogle(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		switch r.URL.Path {
+		case "/calendars/primary/events":
+			writeJSON(w, &calendar.Events{})
+		case "/calendars/primary/events/abc":
+			writeJSON(w, &calendar.Event{Id: "abc", Status: "confirmed"})
+		default:
+			googleError(w, http.StatusNotFound, "notFound")
+		}
+	})
+	rt := mux.NewRouter()
+	RegisterRoutes(rt, "/api")
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/api/events/export.ics?start=2024-01-01&end=2024-02-01", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("export: status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	// While a single event gets the shorter Timeout
+	w = httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/api/event/abc", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("event: status = %d, want %d: %s", w.Code, http.StatusGatewayTimeout, w.Body.String())
+	}
+}
@@ -0,0 +1,82 @@
+package calendar
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// sanitizeHTML strips s down to the tags and attributes in DescriptionAllowlist.
+// Other tags are dropped but their text kept, except script and style whose
+// content is dropped too. Links are only kept for http, https and mailto
+func sanitizeHTML(s string) string {
+	if !strings.ContainsAny(s, "<&") {
+		return s
+	}
+	var b bytes.Buffer
+	skip := 0 // depth inside script or style
+	z := xhtml.NewTokenizer(strings.NewReader(s))
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			return b.String()
+		}
+		tok := z.Token()
+		switch tt {
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if tok.Data == "script" || tok.Data == "style" {
+				if tt == xhtml.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			allowed, ok := DescriptionAllowlist[tok.Data]
+			if !ok || skip > 0 {
+				continue
+			}
+			var attrs []xhtml.Attribute
+			for _, a := range tok.Attr {
+				if contains(allowed, a.Key) && safeAttr(a) {
+					attrs = append(attrs, a)
+				}
+			}
+			tok.Attr = attrs
+			b.WriteString(tok.String())
+		case xhtml.EndTagToken:
+			if tok.Data == "script" || tok.Data == "style" {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if _, ok := DescriptionAllowlist[tok.Data]; ok && skip == 0 {
+				b.WriteString(tok.String())
+			}
+		case xhtml.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(tok.Data))
+			}
+		}
+	}
+}
+
+// safeAttr rejects URL attributes using schemes such as javascript:
+func safeAttr(a xhtml.Attribute) bool {
+	if a.Key != "href" && a.Key != "src" {
+		return true
+	}
+	v := strings.ToLower(strings.TrimSpace(a.Val))
+	return strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://") ||
+		strings.HasPrefix(v, "mailto:")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
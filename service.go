@@ -27,17 +27,17 @@ type CalService struct{}
 
 // getClient uses a Context and Config to retrieve a Token
 // then generate a Client. It returns the generated Client.
-func getClient(ctx context.Context, config *oauth2.Config) *http.Client {
+func getClient(ctx context.Context, config *oauth2.Config) (*http.Client, error) {
 	cacheFile, err := tokenCacheFile()
 	if err != nil {
-		log.Fatalf("Unable to get path to cached credential file. %v", err)
+		return nil, fmt.Errorf("Unable to get path to cached credential file. %v", err)
 	}
 	tok, err := tokenFromFile(cacheFile)
 	if err != nil {
 		tok = getTokenFromWeb(config)
 		saveToken(cacheFile, tok)
 	}
-	return config.Client(ctx, tok)
+	return config.Client(ctx, tok), nil
 }
 
 // getTokenFromWeb uses Config to request a Token.
@@ -95,25 +95,38 @@ func saveToken(file string, token *oauth2.Token) {
 	json.NewEncoder(f).Encode(token)
 }
 
-// New returns service
+// New returns service, exiting when it can't be created
 func (s *CalService) New() *calendar.Service {
+	srv, err := s.Open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return srv
+}
+
+// Open returns service, or an error when the credentials can't be loaded
+func (s *CalService) Open() (*calendar.Service, error) {
 	ctx := context.Background()
 	b, err := ioutil.ReadFile(filepath.Join(credDir, "client_secret.json"))
 	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+		return nil, fmt.Errorf("Unable to read client secret file: %v", err)
 	}
 
 	config, err := google.ConfigFromJSON(b, calendar.CalendarScope)
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		return nil, fmt.Errorf("Unable to parse client secret file to config: %v", err)
 	}
 
-	client := getClient(ctx, config)
+	client, err := getClient(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = &tracingTransport{base: &breakerTransport{base: client.Transport}}
 
 	srv, err := calendar.New(client)
 	if err != nil {
-		log.Fatalf("Unable to retrieve calendar Client %v", err)
+		return nil, fmt.Errorf("Unable to retrieve calendar Client %v", err)
 	}
 
-	return srv
+	return srv, nil
 }
@@ -0,0 +1,39 @@
+package calendar
+
+import (
+	"log"
+	"net/http"
+)
+
+// userSettings lists the calendar settings returned by UserSettings
+var userSettings = []string{"timezone", "weekStart", "format24HourTime", "locale",
+	"dateFieldOrder", "defaultEventLength"}
+
+// UserSettings method fetches the authenticated user's calendar settings
+// relevant to rendering, e.g. {"timezone": "America/Toronto", "weekStart": "0"}
+func UserSettings(w http.ResponseWriter, r *http.Request) {
+	r, span := startSpan(r, "UserSettings")
+	defer span.End()
+
+	// Restrict method to get only
+	if r.Method != "GET" {
+		respondErr(w, r, http.StatusMethodNotAllowed, "invalid method: "+r.Method)
+		return
+	}
+
+	settings, err := srv.Settings.List().Context(r.Context()).Do()
+	if err != nil {
+		log.Println(err.Error())
+		respondError(w, r, http.StatusInternalServerError, err, "Unable to retrieve user's settings")
+		return
+	}
+	res := map[string]string{}
+	for _, st := range settings.Items {
+		for _, id := range userSettings {
+			if st.Id == id {
+				res[id] = st.Value
+			}
+		}
+	}
+	respond(w, r, http.StatusOK, res)
+}
@@ -0,0 +1,63 @@
+package calendar
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+)
+
+// Tracer starts spans. Assign an OpenTelemetry (or other) adapter to Trace
+// to get a span per handler and a child span per Google API call. Incoming
+// trace context is picked up from the request context, so wrap the router
+// with your propagating middleware (e.g. otelhttp) first
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is what we record against a started span
+type Span interface {
+	SetAttribute(key string, value interface{})
+	SetError(err error)
+	End()
+}
+
+// Trace is the Tracer in use, by default one that records nothing
+var Trace Tracer = noopTracer{}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) SetError(err error)                         {}
+func (noopSpan) End()                                       {}
+
+// startSpan starts the span for a handler and returns the request carrying it,
+// so that calls made with .Context(r.Context()) become its children
+func startSpan(r *http.Request, name string) (*http.Request, Span) {
+	ctx, span := Trace.Start(r.Context(), name)
+	span.SetAttribute("http.method", r.Method)
+	return r.WithContext(ctx), span
+}
+
+// tracingTransport wraps each Google API call in a child span of the request context
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Trace.Start(req.Context(), "calendar "+req.Method+" "+req.URL.Path)
+	defer span.End()
+	span.SetAttribute("http.method", req.Method)
+	res, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", res.StatusCode)
+	return res, nil
+}